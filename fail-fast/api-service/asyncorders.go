@@ -0,0 +1,116 @@
+// api-service/asyncorders.go
+// QUEUE_ASYNC: queued checkouts answer 202 with a job ID to poll at /api/orders/{id}
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	queueAsync  = getEnvBool("QUEUE_ASYNC", false)
+	asyncOrders = &AsyncOrderStore{ttl: getEnvDuration("ORDER_RESULT_TTL", 10*time.Minute), orders: map[string]*AsyncOrder{}}
+)
+
+// One checkout handed to the worker pool without the client waiting
+type AsyncOrder struct {
+	seq      int64 // position in the pool's enqueue order
+	done     bool
+	result   CheckoutResult
+	finished time.Time
+}
+
+// Pending and finished async orders; finished ones are kept for ttl
+type AsyncOrderStore struct {
+	ttl    time.Duration
+	orders map[string]*AsyncOrder
+	mu     sync.Mutex
+}
+
+func (s *AsyncOrderStore) add(id string, seq int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for orderID, order := range s.orders {
+		if order.done && now.Sub(order.finished) > s.ttl {
+			delete(s.orders, orderID)
+		}
+	}
+	s.orders[id] = &AsyncOrder{seq: seq}
+}
+
+func (s *AsyncOrderStore) finish(id string, result CheckoutResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if order, ok := s.orders[id]; ok {
+		order.done = true
+		order.result = result
+		order.finished = time.Now()
+	}
+}
+
+func (s *AsyncOrderStore) get(id string) (AsyncOrder, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[id]
+	if !ok || (order.done && time.Since(order.finished) > s.ttl) {
+		return AsyncOrder{}, false
+	}
+	return *order, true
+}
+
+// Enqueue a checkout that would otherwise wait for a worker and answer 202
+// straight away. The job runs on a detached context since the handler
+// returns before it does; onDone sees the final result.
+func submitAsync(r *http.Request, req CheckoutRequest, start time.Time, onDone func(CheckoutResult)) CheckoutResult {
+	detached := r.WithContext(context.WithoutCancel(r.Context()))
+	job := checkoutJob{r: detached, req: req, start: start, result: make(chan CheckoutResult, 1)}
+	seq, ok := checkoutPool.enqueue(job)
+	if !ok {
+		return queueFullResult(r)
+	}
+
+	id := "ord_" + newRequestID()
+	asyncOrders.add(id, seq)
+	go func() {
+		result := <-job.result
+		asyncOrders.finish(id, result)
+		onDone(result)
+	}()
+
+	position := checkoutPool.position(seq)
+	log.Printf("📬 QUEUED ASYNC: %s as %s at position %d [%s]", req.Item, id, position, requestID(r))
+	return CheckoutResult{http.StatusAccepted, map[string]interface{}{
+		"status":         "queued",
+		"order_id":       id,
+		"queue_position": position,
+		"poll_url":       "/api/orders/" + id,
+	}}
+}
+
+// Queued and processing orders answer 202; finished ones return the
+// checkout's own status and body
+func handleOrder(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/orders/")
+	order, ok := asyncOrders.get(id)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Unknown or expired order"})
+		return
+	}
+	if order.done {
+		writeJSON(w, order.result.Status, order.result.Body)
+		return
+	}
+
+	response := map[string]interface{}{"order_id": id, "status": "processing"}
+	if position := checkoutPool.position(order.seq); position > 0 {
+		response["status"] = "queued"
+		response["queue_position"] = position
+	}
+	w.Header().Set("Retry-After", "1")
+	writeJSON(w, http.StatusAccepted, response)
+}
@@ -0,0 +1,74 @@
+// api-service/baseline.go
+// capture a baseline snapshot mid-run and report changes against it
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// Snapshot captured by POST /metrics/baseline; stored in Metrics.Baseline
+type MetricsBaseline struct {
+	Snapshot MetricsSnapshot
+	TakenAt  time.Time
+}
+
+// Change since the baseline; rates in percentage points
+type MetricsDelta struct {
+	BaselineTakenAt   string  `json:"baseline_taken_at"`
+	Elapsed           string  `json:"elapsed"`
+	RequestsAdded     int     `json:"requests_added"`
+	SuccessesAdded    int     `json:"successes_added"`
+	FailuresAdded     int     `json:"failures_added"`
+	FastFailsAdded    int     `json:"fast_fails_added"`
+	SuccessRateChange float64 `json:"success_rate_change"`
+	P99Change         string  `json:"p99_change"`
+}
+
+func handleMetricsBaseline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Breaker first, then the metrics lock (see currentSnapshot)
+	state, counts := cb.State(), cb.Counts()
+	metrics.mu.Lock()
+	baseline := &MetricsBaseline{Snapshot: metrics.snapshotLocked(state, counts), TakenAt: time.Now()}
+	metrics.Baseline = baseline
+	metrics.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":         "baseline captured",
+		"taken_at":       baseline.TakenAt.UTC().Format(time.RFC3339),
+		"total_requests": baseline.Snapshot.TotalRequests,
+	})
+}
+
+func handleMetricsDelta(w http.ResponseWriter, r *http.Request) {
+	state, counts := cb.State(), cb.Counts()
+	metrics.mu.Lock()
+	baseline := metrics.Baseline
+	current := metrics.snapshotLocked(state, counts)
+	metrics.mu.Unlock()
+
+	if baseline == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{
+			"error": "No baseline captured; POST /metrics/baseline first",
+		})
+		return
+	}
+
+	before := baseline.Snapshot
+	writeJSON(w, http.StatusOK, MetricsDelta{
+		BaselineTakenAt:   baseline.TakenAt.UTC().Format(time.RFC3339),
+		Elapsed:           time.Since(baseline.TakenAt).Round(time.Millisecond).String(),
+		RequestsAdded:     current.TotalRequests - before.TotalRequests,
+		SuccessesAdded:    current.SuccessCount - before.SuccessCount,
+		FailuresAdded:     current.FailureCount - before.FailureCount,
+		FastFailsAdded:    current.FastFails - before.FastFails,
+		SuccessRateChange: current.SuccessRate - before.SuccessRate,
+		P99Change:         (current.p99 - before.p99).String(),
+	})
+}
@@ -0,0 +1,92 @@
+// api-service/batch.go
+// batched checkout: many independent checkouts in one HTTP call
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+var (
+	maxBatchSize     = getEnvInt("MAX_BATCH_SIZE", 50)
+	batchConcurrency = getEnvInt("BATCH_CONCURRENCY", 5)
+)
+
+// Marks the requests handed to processCheckout for each batch line
+const batchLineKey contextKey = "batch_line"
+
+// Lines are separate orders even though they share the batch's headers
+func isBatchLine(r *http.Request) bool {
+	return r.Context().Value(batchLineKey) != nil
+}
+
+// Per-element result; each item succeeds or fails on its own
+type BatchItemResult struct {
+	Index  int         `json:"index"`
+	Status int         `json:"status"`
+	Result interface{} `json:"result"`
+}
+
+func handleCheckoutBatch(w http.ResponseWriter, r *http.Request) {
+	if !acceptBody(w, r) {
+		return
+	}
+
+	var reqs []CheckoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+		rejectBody(w, err)
+		return
+	}
+	if len(reqs) > maxBatchSize {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]interface{}{
+			"error":          "Batch too large",
+			"max_batch_size": maxBatchSize,
+		})
+		return
+	}
+
+	// Process items through the breaker with a bounded worker pool
+	line := r.WithContext(context.WithValue(r.Context(), batchLineKey, true))
+	results := make([]BatchItemResult, len(reqs))
+	sem := make(chan struct{}, max(batchConcurrency, 1))
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, req CheckoutRequest) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			// Outside withRecovery's reach: a panic fails only this line
+			defer func() {
+				if rec := recover(); rec != nil {
+					log.Printf("💥 PANIC [%s] in batch line %d: %v\n%s", requestID(r), i, rec, debug.Stack())
+					recordPanic()
+					results[i] = BatchItemResult{Index: i, Status: http.StatusInternalServerError, Result: map[string]string{
+						"error":      "Internal server error",
+						"request_id": requestID(r),
+					}}
+				}
+			}()
+			res := processCheckout(line, req, time.Now())
+			results[i] = BatchItemResult{Index: i, Status: res.Status, Result: res.Body}
+		}(i, req)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, res := range results {
+		if res.Status == http.StatusOK {
+			succeeded++
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"results":   results,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+	})
+}
@@ -0,0 +1,142 @@
+// api-service/bulkhead.go
+// concurrency limit on downstream calls, optionally tuned AIMD-style
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// Multiplicative decrease applied when a window misses its targets
+const adaptiveBackoffRatio = 0.9
+
+// nil when BULKHEAD_MAX_CONCURRENCY is unset: calls are not limited
+var bulkhead = newBulkhead()
+
+// Semaphore whose size can change at runtime. With ADAPTIVE_CONCURRENCY the
+// limit grows by one per window while success rate and latency meet their
+// targets and shrinks by adaptiveBackoffRatio when they don't, staying
+// within [BULKHEAD_MIN_CONCURRENCY, BULKHEAD_MAX_CONCURRENCY].
+type Bulkhead struct {
+	limit    int
+	min      int
+	max      int
+	inFlight int
+	rejected int
+
+	adaptive      bool
+	interval      time.Duration
+	successTarget float64
+	latencyTarget time.Duration
+
+	// Downstream calls finished in the current window
+	calls     int
+	successes int
+	latency   time.Duration
+
+	mu sync.Mutex
+}
+
+// Bulkhead state as reported in /metrics
+type BulkheadStats struct {
+	Limit    int  `json:"limit"`
+	InFlight int  `json:"in_flight"`
+	Rejected int  `json:"rejected"`
+	Adaptive bool `json:"adaptive"`
+}
+
+func newBulkhead() *Bulkhead {
+	// Read every setting up front so they all count as known config keys
+	maxConcurrency := getEnvInt("BULKHEAD_MAX_CONCURRENCY", 0)
+	b := &Bulkhead{
+		limit:         maxConcurrency,
+		min:           min(max(getEnvInt("BULKHEAD_MIN_CONCURRENCY", 1), 1), maxConcurrency),
+		max:           maxConcurrency,
+		adaptive:      getEnvBool("ADAPTIVE_CONCURRENCY", false),
+		interval:      getEnvDuration("ADAPTIVE_INTERVAL", time.Second),
+		successTarget: getEnvFloat("ADAPTIVE_SUCCESS_TARGET", 0.9),
+		latencyTarget: getEnvDuration("ADAPTIVE_LATENCY_TARGET", 500*time.Millisecond),
+	}
+	if maxConcurrency <= 0 {
+		return nil
+	}
+	if b.adaptive {
+		log.Printf("🚧 Adaptive bulkhead: %d-%d concurrent calls, targeting %.0f%% success under %s",
+			b.min, b.max, b.successTarget*100, b.latencyTarget)
+	} else {
+		log.Printf("🚧 Bulkhead: at most %d concurrent downstream calls", b.max)
+	}
+	return b
+}
+
+// Periodically re-size the limit from the last window's calls
+func (b *Bulkhead) startAdaptive() {
+	if !b.adaptive {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			b.adjust()
+		}
+	}()
+}
+
+func (b *Bulkhead) acquire() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.inFlight >= b.limit {
+		b.rejected++
+		return false
+	}
+	b.inFlight++
+	return true
+}
+
+// Free a slot and feed the finished call into the current window. Breaker
+// rejections never reached the downstream and say nothing about its health.
+func (b *Bulkhead) release(err error, latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.inFlight--
+	if err == gobreaker.ErrOpenState || err == gobreaker.ErrTooManyRequests {
+		return
+	}
+	b.calls++
+	b.latency += latency
+	if isBreakerSuccess(err) {
+		b.successes++
+	}
+}
+
+func (b *Bulkhead) adjust() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.calls == 0 {
+		return
+	}
+
+	successRate := float64(b.successes) / float64(b.calls)
+	avgLatency := b.latency / time.Duration(b.calls)
+	previous := b.limit
+	if successRate >= b.successTarget && avgLatency <= b.latencyTarget {
+		b.limit = min(b.limit+1, b.max)
+	} else {
+		b.limit = max(int(float64(b.limit)*adaptiveBackoffRatio), b.min)
+	}
+	if b.limit != previous {
+		log.Printf("🚧 Bulkhead limit %d → %d (success %.0f%%, avg latency %s)",
+			previous, b.limit, successRate*100, avgLatency.Round(time.Millisecond))
+	}
+	b.calls, b.successes, b.latency = 0, 0, 0
+}
+
+func (b *Bulkhead) stats() BulkheadStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BulkheadStats{Limit: b.limit, InFlight: b.inFlight, Rejected: b.rejected, Adaptive: b.adaptive}
+}
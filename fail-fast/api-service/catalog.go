@@ -0,0 +1,64 @@
+// api-service/catalog.go
+// server-side price integrity: checkout prices must match a known catalog
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+)
+
+var (
+	catalogTolerance = getEnvFloat("CATALOG_PRICE_TOLERANCE", 0.01)
+	catalog          = loadCatalog()
+)
+
+// Catalog maps an item ID to its unit price in the base currency
+type Catalog map[string]float64
+
+// Load the catalog from CATALOG_FILE; price validation is disabled when unset
+func loadCatalog() Catalog {
+	path := getEnvString("CATALOG_FILE", "")
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("❌ Could not read CATALOG_FILE: %v", err)
+	}
+	var items Catalog
+	if err := json.Unmarshal(data, &items); err != nil {
+		log.Fatalf("❌ Could not parse CATALOG_FILE: %v", err)
+	}
+	for item, price := range items {
+		if price < 0 {
+			log.Fatalf("❌ CATALOG_FILE: price for %s must not be negative", item)
+		}
+	}
+	log.Printf("📒 Loaded %d catalog prices (tolerance %.4f %s)", len(items), catalogTolerance, baseCurrency)
+	return items
+}
+
+// Check a unit price, already converted to the base currency, against the
+// catalog. Unknown items are 404 and mismatched prices 422.
+func (c Catalog) validate(item string, unitPrice float64) *CheckoutResult {
+	price, ok := c[item]
+	if !ok {
+		return &CheckoutResult{http.StatusNotFound, map[string]string{
+			"error": fmt.Sprintf("Unknown item %q", item),
+		}}
+	}
+	if math.Abs(unitPrice-price) > catalogTolerance {
+		return &CheckoutResult{http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":         "Price does not match catalog",
+			"item":          item,
+			"price":         unitPrice,
+			"catalog_price": price,
+		}}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+// api-service/circuitstate_test.go
+// /circuit-state output for the main and per-tenant breakers
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+func circuitState(t *testing.T, target string) map[string]json.RawMessage {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handleCircuitState(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s: status %d: %s", target, rec.Code, rec.Body)
+	}
+	var state map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil {
+		t.Fatalf("GET %s: %v", target, err)
+	}
+	return state
+}
+
+func TestErrorBudgetOnlyOnMainBreaker(t *testing.T) {
+	savedCB, savedBudget := cb, errorBudget
+	t.Cleanup(func() { cb, errorBudget = savedCB, savedBudget })
+	cb = gobreaker.NewCircuitBreaker(breakerSettings("payment-service"))
+	errorBudget = newErrorBudget(5, time.Minute)
+	if _, ok := tenantBreakers.get("acme"); !ok {
+		t.Fatal("could not create the tenant breaker")
+	}
+
+	if _, ok := circuitState(t, "/circuit-state")["error_budget"]; !ok {
+		t.Error("main breaker: error_budget missing")
+	}
+	if _, ok := circuitState(t, "/circuit-state?tenant=acme")["error_budget"]; ok {
+		t.Error("tenant breaker: error_budget reported, but tenants never spend it")
+	}
+}
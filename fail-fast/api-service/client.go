@@ -0,0 +1,61 @@
+// api-service/client.go
+// shared HTTP client for downstream payment calls
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+const paymentTimeout = 3 * time.Second
+
+// One client (and connection pool) for every checkout, instead of a new
+// client per call
+var paymentClient = newPaymentClient()
+
+func newPaymentClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// All traffic goes to a single downstream host, so the per-host idle
+	// pool is sized well above Go's default of 2
+	transport.MaxIdleConns = getEnvInt("HTTP_MAX_IDLE_CONNS", 100)
+	transport.MaxIdleConnsPerHost = getEnvInt("HTTP_MAX_IDLE_CONNS_PER_HOST", 100)
+
+	// Connection-level timeouts, each still capped by the overall
+	// paymentTimeout. Defaults match http.DefaultTransport; a response
+	// header timeout of 0 leaves waiting for headers to paymentTimeout.
+	dialer := &net.Dialer{
+		Timeout:   getEnvDuration("HTTP_DIAL_TIMEOUT", 30*time.Second),
+		KeepAlive: 30 * time.Second,
+	}
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = getEnvDuration("HTTP_TLS_HANDSHAKE_TIMEOUT", 10*time.Second)
+	transport.ResponseHeaderTimeout = getEnvDuration("HTTP_RESPONSE_HEADER_TIMEOUT", 0)
+
+	log.Printf("🔗 Payment client pool: max_idle_conns=%d max_idle_conns_per_host=%d",
+		transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	log.Printf("🔗 Payment client timeouts: request=%s dial=%s tls_handshake=%s response_header=%s",
+		paymentTimeout, dialer.Timeout, transport.TLSHandshakeTimeout, transport.ResponseHeaderTimeout)
+
+	client := &http.Client{
+		Timeout:   paymentTimeout,
+		Transport: transport,
+	}
+
+	// Redirect policy. By default redirects are NOT followed: the 3xx itself
+	// is returned, fails the non-200 check in doPaymentRequest and therefore
+	// counts as a downstream failure for the breaker. A payment endpoint
+	// that redirects is misbehaving, and following it blindly can turn a
+	// failure into a false success from whatever the Location points at.
+	// With HTTP_FOLLOW_REDIRECTS=true Go's default policy applies (up to 10
+	// hops) and only the final response is judged.
+	if getEnvBool("HTTP_FOLLOW_REDIRECTS", false) {
+		log.Println("🔗 Payment client follows redirects")
+	} else {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	return client
+}
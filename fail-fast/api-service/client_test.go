@@ -0,0 +1,64 @@
+// api-service/client_test.go
+// payment client pooling under parallel load
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Parallel payment calls with Go's default of 2 idle connections per host
+// against the tuned pool. With too few idle slots most connections are
+// closed after one call and redialed, which shows up as new-conns/op and
+// ns/op. Calls only overlap enough to show it with several Ps, e.g.
+//
+//	go test -run '^$' -bench PaymentClientPool -cpu 1,4,8
+func BenchmarkPaymentClientPool(b *testing.B) {
+	for _, bench := range []struct {
+		name        string
+		idlePerHost int
+	}{
+		{"default", http.DefaultMaxIdleConnsPerHost},
+		{"tuned", paymentClient.Transport.(*http.Transport).MaxIdleConnsPerHost},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			var dialed atomic.Int64
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(time.Millisecond)
+				w.Write([]byte(`{"success": true}`))
+			}))
+			server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					dialed.Add(1)
+				}
+			}
+			server.Start()
+			defer server.Close()
+
+			transport := paymentClient.Transport.(*http.Transport).Clone()
+			transport.MaxIdleConnsPerHost = bench.idlePerHost
+			defer transport.CloseIdleConnections()
+			client := &http.Client{Transport: transport, Timeout: paymentTimeout}
+
+			b.SetParallelism(16)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					resp, err := client.Get(server.URL + "/process?amount=10.00")
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+			})
+			b.ReportMetric(float64(dialed.Load())/float64(b.N), "new-conns/op")
+		})
+	}
+}
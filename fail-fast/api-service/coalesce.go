@@ -0,0 +1,84 @@
+// api-service/coalesce.go
+// sharing one payment call between identical checkouts in a short window
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// nil when COALESCE_WINDOW is unset: every checkout makes its own call
+var coalescer = newCoalescer(getEnvDuration("COALESCE_WINDOW", 0))
+
+// Repeats of one checkout that arrive while its call is in flight, or
+// within the window after it finished, get that call's result instead of
+// making their own.
+//
+// Correctness: a coalesced group is charged ONCE, so it may only contain
+// the same logical order (double-clicks, client retry storms). Requests are
+// therefore matched on their tenant-scoped Idempotency-Key, plus item and
+// amount so a reused key can't pick up a different order's result. Requests
+// without a key and the lines of a batch, which share their parent's key,
+// never coalesce.
+type Coalescer struct {
+	window time.Duration
+	calls  map[string]*coalescedCall
+	mu     sync.Mutex
+}
+
+type coalescedCall struct {
+	done     chan struct{}
+	finished time.Time // zero while in flight
+	result   interface{}
+	err      error
+}
+
+func newCoalescer(window time.Duration) *Coalescer {
+	if window <= 0 {
+		return nil
+	}
+	log.Printf("🤝 Coalescing identical idempotent checkouts within %s", window)
+	return &Coalescer{window: window, calls: map[string]*coalescedCall{}}
+}
+
+// Key for a checkout, or "" when it must not be coalesced
+func coalesceKey(r *http.Request, item string, charged float64) string {
+	key := idempotencyKey(r)
+	if key == "" || isBatchLine(r) {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/%.2f", key, item, charged)
+}
+
+// Run fn, or wait for and share the result of a matching call; reports
+// whether the result was shared
+func (c *Coalescer) do(key string, fn func() (interface{}, error)) (interface{}, bool, error) {
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok && (call.finished.IsZero() || time.Since(call.finished) < c.window) {
+		c.mu.Unlock()
+		<-call.done
+		return call.result, true, call.err
+	}
+	call := &coalescedCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		call.finished = time.Now()
+		c.mu.Unlock()
+		close(call.done)
+		time.AfterFunc(c.window, func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.calls[key] == call {
+				delete(c.calls, key)
+			}
+		})
+	}()
+	call.result, call.err = fn()
+	return call.result, false, call.err
+}
@@ -0,0 +1,204 @@
+// api-service/config.go
+// settings lookup: environment variables first, then the optional CONFIG_FILE
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// Values from CONFIG_FILE, keyed by the same names as the env vars
+	fileSettings = loadConfigFile(os.Getenv("CONFIG_FILE"), "api")
+
+	// Every setting read so far and the value it resolved to
+	resolvedSettings   = map[string]string{}
+	resolvedSettingsMu sync.Mutex
+)
+
+// Settings whose values are never logged
+var secretSettings = map[string]bool{
+	"REDIS_URL":         true,
+	"ADMIN_TOKEN":       true,
+	"STATE_WEBHOOK_URL": true, // webhook URLs often embed a token
+}
+
+// Load a JSON or YAML object of setting names to scalar values, e.g.
+// {"CB_MIN_REQUESTS": 10, "HEALTH_CHECK_INTERVAL": "5s"}. A file shared with
+// flaky-service nests them under "api" and "flaky" sections instead, and
+// only this service's section is read.
+func loadConfigFile(path string, section string) map[string]string {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("❌ Could not read CONFIG_FILE: %v", err)
+	}
+
+	var raw map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	default:
+		err = json.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		log.Fatalf("❌ Could not parse CONFIG_FILE %s: %v", path, err)
+	}
+	raw = configSection(raw, section)
+
+	settings := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch value.(type) {
+		case string, bool, int, float64:
+			settings[key] = fmt.Sprint(value)
+		default:
+			log.Fatalf("❌ CONFIG_FILE: %s must be a string, number or boolean", key)
+		}
+	}
+	log.Printf("📄 Loaded %d settings from %s", len(settings), path)
+	return settings
+}
+
+// The named section of a sectioned file, or the whole file when it has no
+// sections. Other services' sections are left to them to validate.
+func configSection(raw map[string]interface{}, section string) map[string]interface{} {
+	sectioned := false
+	for _, value := range raw {
+		if _, ok := value.(map[string]interface{}); ok {
+			sectioned = true
+			break
+		}
+	}
+	if !sectioned {
+		return raw
+	}
+	for key, value := range raw {
+		if _, ok := value.(map[string]interface{}); !ok {
+			log.Fatalf("❌ CONFIG_FILE: %s is outside any section", key)
+		}
+	}
+	own, _ := raw[section].(map[string]interface{})
+	return own
+}
+
+// Look up a setting: the environment overrides the config file
+func getSetting(key string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fileSettings[key]
+}
+
+func noteSetting(key string, value interface{}) {
+	resolvedSettingsMu.Lock()
+	defer resolvedSettingsMu.Unlock()
+	resolvedSettings[key] = fmt.Sprint(value)
+}
+
+// Get a string setting with default
+func getEnvString(key string, fallback string) string {
+	value := getSetting(key)
+	if value == "" {
+		value = fallback
+	}
+	noteSetting(key, value)
+	return value
+}
+
+// Get a duration setting from the environment with default. 0 is only
+// accepted for settings that default to 0, where it means off.
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	result := fallback
+	if value := getSetting(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && (d > 0 || d == 0 && fallback == 0) {
+			result = d
+		} else {
+			log.Printf("⚠️ Invalid %s %q, using %s", key, value, fallback)
+		}
+	}
+	noteSetting(key, result)
+	return result
+}
+
+// Get an integer setting from the environment with default
+func getEnvInt(key string, fallback int) int {
+	result := fallback
+	if value := getSetting(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			result = n
+		} else {
+			log.Printf("⚠️ Invalid %s %q, using %d", key, value, fallback)
+		}
+	}
+	noteSetting(key, result)
+	return result
+}
+
+// Get a float setting from the environment with default
+func getEnvFloat(key string, fallback float64) float64 {
+	result := fallback
+	if value := getSetting(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			result = f
+		} else {
+			log.Printf("⚠️ Invalid %s %q, using %g", key, value, fallback)
+		}
+	}
+	noteSetting(key, result)
+	return result
+}
+
+// Get a boolean setting from the environment with default
+func getEnvBool(key string, fallback bool) bool {
+	result := fallback
+	if value := getSetting(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			result = b
+		} else {
+			log.Printf("⚠️ Invalid %s %q, using %t", key, value, fallback)
+		}
+	}
+	noteSetting(key, result)
+	return result
+}
+
+// Reject unknown keys in CONFIG_FILE (likely typos) and log every setting's
+// effective value. Run from main, after all settings have been read.
+func logEffectiveConfig() {
+	resolvedSettingsMu.Lock()
+	defer resolvedSettingsMu.Unlock()
+
+	for key := range fileSettings {
+		if _, known := resolvedSettings[key]; !known {
+			log.Fatalf("❌ CONFIG_FILE: unknown setting %s", key)
+		}
+	}
+
+	keys := make([]string, 0, len(resolvedSettings))
+	for key := range resolvedSettings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	log.Println("⚙️ Effective configuration:")
+	for _, key := range keys {
+		value := resolvedSettings[key]
+		if secretSettings[key] && value != "" {
+			value = "[REDACTED]"
+		}
+		log.Printf("⚙️   %s=%s", key, value)
+	}
+}
@@ -0,0 +1,41 @@
+// api-service/config_test.go
+// CONFIG_FILE shared with flaky-service through per-service sections
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSharedConfigFileSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	shared := `
+api:
+  CB_MIN_REQUESTS: 10
+  HEALTH_CHECK_INTERVAL: 5s
+flaky:
+  FLAKY_FAIL_FIRST_N: 5
+`
+	if err := os.WriteFile(path, []byte(shared), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := loadConfigFile(path, "api")
+	if len(settings) != 2 || settings["CB_MIN_REQUESTS"] != "10" || settings["HEALTH_CHECK_INTERVAL"] != "5s" {
+		t.Errorf("api section read as %v", settings)
+	}
+	if _, ok := settings["FLAKY_FAIL_FIRST_N"]; ok {
+		t.Error("flaky-service's setting leaked into the api section")
+	}
+}
+
+func TestFlatConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"CB_MIN_REQUESTS": 10}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if settings := loadConfigFile(path, "api"); len(settings) != 1 || settings["CB_MIN_REQUESTS"] != "10" {
+		t.Errorf("flat file read as %v", settings)
+	}
+}
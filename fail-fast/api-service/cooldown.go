@@ -0,0 +1,39 @@
+// api-service/cooldown.go
+// randomized open-state cooldown so a fleet doesn't probe in lockstep
+package main
+
+import (
+	"log"
+	"math/rand"
+	"time"
+)
+
+// Extra time, chosen at random per open transition, that the circuit stays
+// open beyond breakerTimeout. gobreaker's Timeout is fixed, so it acts as the
+// floor and the extra wait is enforced in front of the breaker.
+var breakerTimeoutJitter = getBreakerTimeoutJitter()
+
+func getBreakerTimeoutJitter() time.Duration {
+	jitter := getEnvDuration("CB_TIMEOUT_JITTER", 0)
+	if jitter > 0 {
+		log.Printf("🎲 Open-state cooldown jittered between %s and %s", breakerTimeout, breakerTimeout+jitter)
+	}
+	return jitter
+}
+
+// Cooldown for one open period: breakerTimeout plus up to breakerTimeoutJitter
+func jitteredTimeout() time.Duration {
+	if breakerTimeoutJitter <= 0 {
+		return breakerTimeout
+	}
+	return breakerTimeout + time.Duration(rand.Int63n(int64(breakerTimeoutJitter)+1))
+}
+
+// Whether the jittered cooldown is still running. Once breakerTimeout has
+// elapsed gobreaker would admit half-open probes on its own, so checkouts are
+// held back here until this instance's randomized deadline has passed.
+func inOpenCooldown() bool {
+	openedAtMu.Lock()
+	defer openedAtMu.Unlock()
+	return time.Now().Before(openUntil)
+}
@@ -0,0 +1,111 @@
+// api-service/currency.go
+// static-rate currency conversion applied before charging
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	baseCurrency = getBaseCurrency()
+	exchange     = loadRates()
+)
+
+// Rates map a currency code to how many base-currency units one unit is worth
+type RateTable map[string]float64
+
+func getBaseCurrency() string {
+	return strings.ToUpper(getEnvString("BASE_CURRENCY", "USD"))
+}
+
+// Load the rate table from RATES_FILE; conversion is disabled when unset
+func loadRates() RateTable {
+	path := getEnvString("RATES_FILE", "")
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("❌ Could not read RATES_FILE: %v", err)
+	}
+	var raw map[string]float64
+	if err := json.Unmarshal(data, &raw); err != nil {
+		log.Fatalf("❌ Could not parse RATES_FILE: %v", err)
+	}
+
+	rates := RateTable{baseCurrency: 1}
+	for code, rate := range raw {
+		if rate <= 0 {
+			log.Fatalf("❌ RATES_FILE: rate for %s must be positive", code)
+		}
+		rates[strings.ToUpper(code)] = rate
+	}
+	log.Printf("💱 Loaded %d exchange rates (base %s)", len(rates), baseCurrency)
+	return rates
+}
+
+// Display conventions for a currency: minor-unit digits and where the symbol goes
+type CurrencyFormat struct {
+	Decimals int
+	Symbol   string
+	Suffix   bool // symbol after the amount, e.g. "100,00 kr"
+}
+
+// Currencies with a known display format. A checkout may name one of these
+// or any currency in RATES_FILE; anything else is rejected with 422.
+var currencyFormats = map[string]CurrencyFormat{
+	"USD": {Decimals: 2, Symbol: "$"},
+	"CAD": {Decimals: 2, Symbol: "CA$"},
+	"AUD": {Decimals: 2, Symbol: "A$"},
+	"EUR": {Decimals: 2, Symbol: "€"},
+	"GBP": {Decimals: 2, Symbol: "£"},
+	"CHF": {Decimals: 2, Symbol: "CHF "},
+	"INR": {Decimals: 2, Symbol: "₹"},
+	"SEK": {Decimals: 2, Symbol: " kr", Suffix: true},
+	"JPY": {Decimals: 0, Symbol: "¥"},
+	"KRW": {Decimals: 0, Symbol: "₩"},
+}
+
+func supportedCurrency(currency string) bool {
+	currency = strings.ToUpper(currency)
+	if _, ok := currencyFormats[currency]; ok {
+		return true
+	}
+	_, ok := exchange[currency]
+	return ok
+}
+
+// Format an amount the way the currency is usually written, e.g. "¥1500"
+// or "$12.50". Codes without a known format fall back to "12.50 XYZ".
+func formatMoney(amount float64, currency string) string {
+	currency = strings.ToUpper(currency)
+	format, ok := currencyFormats[currency]
+	if !ok {
+		return fmt.Sprintf("%.2f %s", amount, currency)
+	}
+	number := strconv.FormatFloat(amount, 'f', format.Decimals, 64)
+	if format.Suffix {
+		return number + format.Symbol
+	}
+	return format.Symbol + number
+}
+
+// Convert an amount to the base currency. An empty currency means the
+// amount is already in the base currency.
+func (rates RateTable) toBase(amount float64, currency string) (float64, error) {
+	currency = strings.ToUpper(currency)
+	if currency == "" || currency == baseCurrency {
+		return amount, nil
+	}
+	rate, ok := rates[currency]
+	if !ok {
+		return 0, fmt.Errorf("unsupported currency %q", currency)
+	}
+	return amount * rate, nil
+}
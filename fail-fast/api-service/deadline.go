@@ -0,0 +1,29 @@
+// api-service/deadline.go
+// client-supplied latency budgets (X-Timeout-Ms) and remaining-time reporting
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Smallest remaining budget worth spending on a downstream call; below this
+// the checkout fast-fails instead of starting a call it can't finish
+var minCallBudget = getEnvDuration("MIN_CALL_BUDGET", 10*time.Millisecond)
+
+// Deadline for a request that arrived at start, from its X-Timeout-Ms header.
+// Missing or non-positive values mean no budget.
+func requestDeadline(r *http.Request, start time.Time) (time.Time, bool) {
+	ms, err := strconv.Atoi(r.Header.Get("X-Timeout-Ms"))
+	if err != nil || ms <= 0 {
+		return time.Time{}, false
+	}
+	return start.Add(time.Duration(ms) * time.Millisecond), true
+}
+
+// Report how much of the budget is left, never less than zero
+func setTimeRemaining(w http.ResponseWriter, deadline time.Time) {
+	remaining := max(time.Until(deadline), 0)
+	w.Header().Set("X-Time-Remaining-Ms", strconv.FormatInt(remaining.Milliseconds(), 10))
+}
@@ -0,0 +1,134 @@
+// api-service/debug.go
+// opt-in raw payload and sampled per-request logging for diagnosing client integrations
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sony/gobreaker"
+)
+
+var (
+	debugLogBody   = getEnvBool("DEBUG_LOG_BODY", false)
+	debugBodyLimit = getDebugBodyLimit()
+	logSampleRate  = getEnvFloat("LOG_SAMPLE_RATE", 0)
+
+	// Checkouts slower than this are always logged, whatever the sample rate
+	slowRequestThreshold = getEnvDuration("SLOW_REQUEST_THRESHOLD", time.Second)
+)
+
+// Bytes of a raw body to log; 0 logs only that there was one
+func getDebugBodyLimit() int {
+	limit := getEnvInt("DEBUG_BODY_LIMIT", 1024)
+	if limit < 0 {
+		log.Printf("⚠️ DEBUG_BODY_LIMIT must not be negative, using 0")
+		limit = 0
+	}
+	return limit
+}
+
+// Fields whose values never make it into the logs or request samples;
+// REDACT_FIELDS adds comma-separated names to the defaults
+var sensitiveFields = getSensitiveFields()
+
+func getSensitiveFields() map[string]bool {
+	fields := map[string]bool{
+		"card_number": true,
+		"cvv":         true,
+		"token":       true,
+		"password":    true,
+	}
+	for _, field := range strings.Split(getEnvString("REDACT_FIELDS", ""), ",") {
+		if field = strings.ToLower(strings.TrimSpace(field)); field != "" {
+			fields[field] = true
+		}
+	}
+	return fields
+}
+
+// Log the raw request payload when DEBUG_LOG_BODY is enabled
+func logRawBody(reason string, body []byte) {
+	if !debugLogBody {
+		return
+	}
+	log.Printf("🐛 %s - raw body: %s", reason, redactBody(body, debugBodyLimit))
+}
+
+// Redact sensitive top-level fields and cap the output at limit bytes, cut
+// back to the start of a character so a multi-byte one is never split.
+// Bodies that aren't a JSON object are logged as-is (still truncated).
+func redactBody(body []byte, limit int) string {
+	text := string(body)
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err == nil {
+		for key := range fields {
+			if sensitiveFields[strings.ToLower(key)] {
+				fields[key] = "[REDACTED]"
+			}
+		}
+		if redacted, err := json.Marshal(fields); err == nil {
+			text = string(redacted)
+		}
+	}
+
+	if len(text) > limit {
+		cut := max(limit, 0)
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		return text[:cut] + "...(truncated)"
+	}
+	return text
+}
+
+// Log full details for a random LOG_SAMPLE_RATE fraction of checkouts,
+// tagged with the request ID so sampled lines can be correlated
+func logSampledCheckout(r *http.Request, req CheckoutRequest, result interface{}, err error, latency time.Duration, state gobreaker.State) {
+	if logSampleRate <= 0 || rand.Float64() >= logSampleRate {
+		return
+	}
+
+	downstreamStatus := 0
+	var statusErr *DownstreamStatusError
+	if resp, ok := result.(*http.Response); ok {
+		downstreamStatus = resp.StatusCode
+	} else if errors.As(err, &statusErr) {
+		downstreamStatus = statusErr.StatusCode
+	}
+
+	outcome := "success"
+	if err != nil {
+		outcome = err.Error()
+	}
+
+	log.Printf("🔎 SAMPLE [%s] item=%q price=%.2f quantity=%d latency=%s state=%s downstream_status=%d outcome=%q",
+		requestID(r), req.Item, req.Price, req.quantity(), latency, state, downstreamStatus, outcome)
+}
+
+// Warn about a tail-latency outlier, with the state of the breaker it used
+func logSlowCheckout(r *http.Request, req CheckoutRequest, status int, duration time.Duration) {
+	if duration <= slowRequestThreshold {
+		return
+	}
+	log.Printf("🐌 SLOW REQUEST [%s] item=%q latency=%s status=%d state=%s (threshold %s)",
+		requestID(r), req.Item, duration, status, requestBreakerState(r), slowRequestThreshold)
+}
+
+// State of the breaker a request used: its tenant's if it has one, else the shared one
+func requestBreakerState(r *http.Request) gobreaker.State {
+	breaker := cb
+	if tenant := r.Header.Get("X-Tenant-ID"); tenant != "" {
+		if tenantBreaker, ok := tenantBreakers.lookup(tenant); ok {
+			breaker = tenantBreaker
+		}
+	}
+	return breaker.State()
+}
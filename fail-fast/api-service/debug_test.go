@@ -0,0 +1,32 @@
+// api-service/debug_test.go
+// raw body truncation never panics or splits a character
+package main
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRedactBodyTruncation(t *testing.T) {
+	body := []byte("café €uro") // é and € are multi-byte
+	tests := []struct {
+		limit int
+		want  string
+	}{
+		{-5, "...(truncated)"},
+		{0, "...(truncated)"},
+		{4, "caf...(truncated)"}, // inside é
+		{5, "café...(truncated)"},
+		{8, "café ...(truncated)"}, // inside €
+		{100, "café €uro"},
+	}
+	for _, tc := range tests {
+		got := redactBody(body, tc.limit)
+		if got != tc.want {
+			t.Errorf("limit %d: got %q, want %q", tc.limit, got, tc.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("limit %d: %q is not valid UTF-8", tc.limit, got)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+//go:build linux
+
+// api-service/dial_test.go
+// a downstream that never accepts hits the dial timeout, not the request one
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Loopback address of a socket that listens with a zero backlog and never
+// accepts. Once its queue is full, further SYNs are dropped and dials hang.
+func saturatedListener(t *testing.T) string {
+	t.Helper()
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Close(fd) })
+	if err := syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Listen(fd, 0); err != nil {
+		t.Fatal(err)
+	}
+	sa, err := syscall.Getsockname(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(sa.(*syscall.SockaddrInet4).Port))
+
+	for i := 0; i < 8; i++ {
+		conn, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+		if err != nil {
+			return addr // queue full
+		}
+		t.Cleanup(func() { conn.Close() })
+	}
+	t.Skip("could not fill the accept queue")
+	return ""
+}
+
+func TestSlowDialHitsDialTimeout(t *testing.T) {
+	addr := saturatedListener(t)
+
+	// Dial timeout well below the response-header and request timeouts
+	t.Setenv("HTTP_DIAL_TIMEOUT", "200ms")
+	t.Setenv("HTTP_RESPONSE_HEADER_TIMEOUT", "2s")
+	saved := paymentClient
+	t.Cleanup(func() { paymentClient = saved })
+	paymentClient = newPaymentClient()
+
+	start := time.Now()
+	_, err := doPaymentRequest(context.Background(), "http://"+addr, 10)
+	elapsed := time.Since(start)
+
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "dial" || !opErr.Timeout() {
+		t.Fatalf("got %v, want a dial timeout", err)
+	}
+	if failure := classifyFailure(err); failure != failureConnection {
+		t.Errorf("classified as %q, want %q", failure, failureConnection)
+	}
+	if elapsed < 200*time.Millisecond || elapsed > time.Second {
+		t.Errorf("failed after %s, want about the 200ms dial timeout", elapsed)
+	}
+}
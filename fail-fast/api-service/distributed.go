@@ -0,0 +1,290 @@
+// api-service/distributed.go
+// optional Redis-backed breaker state shared across api-service instances
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sony/gobreaker"
+)
+
+// Per-call Redis budget. Checkouts never wait on Redis: the open flag is a
+// cached value refreshed in the background and outcomes are queued.
+const redisTimeout = 100 * time.Millisecond
+
+// Fleet-wide failure counts and open flag, keyed by breaker name. Counts
+// expire with the breaker interval and the open flag with the breaker
+// timeout, so stale state ages out on its own. Under CB_SLIDING_WINDOW
+// outcomes also go into per-second buckets that expire with the window, and
+// the counts age out with the window instead.
+//
+// The open flag is polled every REDIS_REFRESH_INTERVAL, so another instance's
+// trip takes up to that long to reach this one. Outcomes wait in a queue of
+// REDIS_OUTCOME_QUEUE; when Redis can't keep up they are dropped and the
+// fleet-wide counts undercount rather than checkouts slowing down.
+type SharedBreakerState struct {
+	client      *redis.Client
+	name        string
+	interval    time.Duration
+	window      time.Duration // 0 without CB_SLIDING_WINDOW
+	openTimeout time.Duration
+	refresh     time.Duration
+	open        atomic.Bool // as of the last refresh
+	outcomes    chan bool   // true for a success
+	dropped     atomic.Int64
+	unavailable atomic.Bool
+}
+
+// nil when REDIS_URL is unset: the in-process breaker is used alone
+var sharedState = newSharedBreakerState()
+
+func newSharedBreakerState() *SharedBreakerState {
+	url := getEnvString("REDIS_URL", "")
+	if url == "" {
+		return nil
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		log.Fatalf("❌ Invalid REDIS_URL: %v", err)
+	}
+	log.Printf("🌐 Sharing breaker state via Redis at %s", opts.Addr)
+	queue := getEnvInt("REDIS_OUTCOME_QUEUE", 1000)
+	if queue < 1 {
+		log.Printf("⚠️ REDIS_OUTCOME_QUEUE must be at least 1, using 1")
+		queue = 1
+	}
+	state := &SharedBreakerState{
+		client:      redis.NewClient(opts),
+		name:        "payment-service",
+		interval:    breakerInterval,
+		openTimeout: breakerTimeout,
+		refresh:     getEnvDuration("REDIS_REFRESH_INTERVAL", 250*time.Millisecond),
+		outcomes:    make(chan bool, queue),
+	}
+	if slidingWindow != nil {
+		state.window = slidingWindow.size()
+		state.interval = state.window
+	}
+	return state
+}
+
+// Poll the open flag and push queued outcomes to Redis until shutdown
+func (s *SharedBreakerState) start() {
+	if s == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.refresh)
+		defer ticker.Stop()
+		s.refreshOpen()
+		for {
+			select {
+			case <-ticker.C:
+				s.refreshOpen()
+			case <-shutdownCtx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		for {
+			select {
+			case success := <-s.outcomes:
+				s.recordOutcome(success)
+				if len(s.outcomes) == 0 {
+					if n := s.dropped.Swap(0); n > 0 {
+						log.Printf("🌐 Redis outcome queue drained, %d outcomes dropped", n)
+					}
+				}
+			case <-shutdownCtx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (s *SharedBreakerState) key(suffix string) string {
+	return "cb:" + s.name + ":" + suffix
+}
+
+// Bucket for the outcomes of one second
+func (s *SharedBreakerState) windowKey(second int64, success bool) string {
+	if success {
+		return s.key("window:" + strconv.FormatInt(second, 10) + ":ok")
+	}
+	return s.key("window:" + strconv.FormatInt(second, 10) + ":fail")
+}
+
+// Success and failure buckets of every second in the window ending at now,
+// interleaved
+func (s *SharedBreakerState) windowKeys(now time.Time) []string {
+	var keys []string
+	for second := now.Unix() - int64(s.window/time.Second) + 1; second <= now.Unix(); second++ {
+		keys = append(keys, s.windowKey(second, true), s.windowKey(second, false))
+	}
+	return keys
+}
+
+// Run fn through the local breaker, but fast-fail if any instance has opened
+// the shared circuit or the jittered cooldown is still running. A manual
+// override (see override.go) takes precedence over all of these. Redis errors
+// fall back to local state only. Tenant breakers are local-only.
+func executePayment(breaker *gobreaker.CircuitBreaker, fn func() (interface{}, error)) (interface{}, error) {
+	if breaker == cb {
+		switch mode, err := breakerOverride.admit(); {
+		case err != nil:
+			return nil, err
+		case mode == overrideClosed:
+			return fn()
+		case mode == overrideHalfOpen:
+			return breakerOverride.probe(fn)
+		}
+	}
+	if breaker == cb && inOpenCooldown() {
+		return nil, gobreaker.ErrOpenState
+	}
+	if sharedState == nil || breaker != cb {
+		return breaker.Execute(fn)
+	}
+
+	if sharedState.isOpen() {
+		return nil, gobreaker.ErrOpenState
+	}
+
+	result, err := cb.Execute(fn)
+	if err != gobreaker.ErrOpenState && err != gobreaker.ErrTooManyRequests {
+		sharedState.record(isBreakerSuccess(err))
+	}
+	return result, err
+}
+
+// Whether the shared circuit was open at the last refresh
+func (s *SharedBreakerState) isOpen() bool {
+	return s.open.Load()
+}
+
+// Re-read the open flag; on a Redis error the last known value is dropped,
+// so an unreachable Redis falls back to local state only
+func (s *SharedBreakerState) refreshOpen() {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	n, err := s.client.Exists(ctx, s.key("open")).Result()
+	s.noteResult(err)
+	s.open.Store(err == nil && n > 0)
+}
+
+// Queue one outcome for the shared counts without waiting; dropped when the
+// queue is full
+func (s *SharedBreakerState) record(success bool) {
+	select {
+	case s.outcomes <- success:
+	default:
+		if s.dropped.Add(1) == 1 {
+			log.Printf("⚠️ Redis outcome queue full, dropping outcomes")
+		}
+	}
+}
+
+// Add one outcome to the shared counts and trip the shared circuit when the
+// fleet-wide counts satisfy the same TripPolicy as the local breaker. The
+// error budget is left out: it is per instance, and the local breaker has
+// already spent it on this failure.
+func (s *SharedBreakerState) recordOutcome(success bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	now := time.Now()
+
+	pipe := s.client.TxPipeline()
+	// Read the window before adding this outcome, as the policy expects
+	var window *redis.SliceCmd
+	if s.window > 0 {
+		window = pipe.MGet(ctx, s.windowKeys(now)...)
+		bucket := s.windowKey(now.Unix(), success)
+		pipe.Incr(ctx, bucket)
+		pipe.Expire(ctx, bucket, s.window+time.Second)
+	}
+	// SET NX starts each counter with its expiry, which INCR keeps (the
+	// same as EXPIRE NX, without needing Redis 7)
+	for _, counter := range []string{"requests", "failures", "consecutive_failures"} {
+		pipe.SetNX(ctx, s.key(counter), 0, s.interval)
+	}
+	requests := pipe.Incr(ctx, s.key("requests"))
+	failures := pipe.IncrBy(ctx, s.key("failures"), 0)
+	consecutive := pipe.IncrBy(ctx, s.key("consecutive_failures"), 0)
+	if success {
+		pipe.Set(ctx, s.key("consecutive_failures"), 0, s.interval)
+	} else {
+		failures = pipe.Incr(ctx, s.key("failures"))
+		consecutive = pipe.Incr(ctx, s.key("consecutive_failures"))
+	}
+	_, err := pipe.Exec(ctx)
+	s.noteResult(err)
+	if err != nil {
+		return
+	}
+
+	counts := gobreaker.Counts{
+		Requests:            uint32(requests.Val()),
+		TotalFailures:       uint32(failures.Val()),
+		ConsecutiveFailures: uint32(consecutive.Val()),
+	}
+	policy := TripPolicy{}
+	if window != nil {
+		policy.window = sumWindow(window.Val())
+	}
+	if !success && policy.readyToTrip(counts, now) {
+		s.trip()
+	}
+}
+
+// Totals from the interleaved MGET of windowKeys; missing buckets are nil
+func sumWindow(values []interface{}) windowTotals {
+	var totals windowTotals
+	for i, value := range values {
+		raw, _ := value.(string)
+		n, _ := strconv.Atoi(raw)
+		if i%2 == 0 {
+			totals.successes += n
+		} else {
+			totals.failures += n
+		}
+	}
+	return totals
+}
+
+// Open the circuit fleet-wide and start a fresh counting window
+func (s *SharedBreakerState) trip() {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, s.key("open"), 1, s.openTimeout)
+	pipe.Del(ctx, s.key("requests"), s.key("failures"), s.key("consecutive_failures"))
+	if s.window > 0 {
+		pipe.Del(ctx, s.windowKeys(time.Now())...)
+	}
+	_, err := pipe.Exec(ctx)
+	s.noteResult(err)
+	if err == nil {
+		s.open.Store(true)
+		log.Println("🌐 Shared circuit OPEN for all instances")
+	}
+}
+
+// Log Redis availability transitions rather than every failed call
+func (s *SharedBreakerState) noteResult(err error) {
+	if err != nil && err != redis.Nil {
+		if !s.unavailable.Swap(true) {
+			log.Printf("⚠️ Redis unavailable, falling back to local breaker state: %v", err)
+		}
+		return
+	}
+	if s.unavailable.Swap(false) {
+		log.Println("🌐 Redis reachable again, sharing breaker state")
+	}
+}
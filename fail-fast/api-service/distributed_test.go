@@ -0,0 +1,33 @@
+// api-service/distributed_test.go
+// shared breaker state never puts Redis on the checkout path
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSharedRecordNeverBlocks(t *testing.T) {
+	// No worker is draining the queue, as when Redis is slow
+	state := &SharedBreakerState{outcomes: make(chan bool, 2)}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			state.record(false)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("record blocked on a full queue")
+	}
+
+	if queued := len(state.outcomes); queued != 2 {
+		t.Errorf("%d outcomes queued, want 2", queued)
+	}
+	if dropped := state.dropped.Load(); dropped != 3 {
+		t.Errorf("%d outcomes dropped, want 3", dropped)
+	}
+}
@@ -0,0 +1,132 @@
+// api-service/errorbudget.go
+// SLO-style tripping: open the circuit once a failure budget is used up
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// nil unless ERROR_BUDGET is set
+var errorBudget = newErrorBudget(
+	getEnvFloat("ERROR_BUDGET", 0),
+	getEnvDuration("ERROR_BUDGET_WINDOW", time.Minute),
+)
+
+// Leaky budget: each failure spends one unit and the full budget refills
+// evenly over the window, so a steady trickle of errors below
+// budget/window never trips while a burst does
+type ErrorBudget struct {
+	budget    float64
+	window    time.Duration
+	remaining float64
+	last      time.Time
+	mu        sync.Mutex
+}
+
+// Budget state as reported in /circuit-state
+type ErrorBudgetStats struct {
+	Budget    float64 `json:"budget"`
+	Window    string  `json:"window"`
+	Remaining float64 `json:"remaining"`
+}
+
+func newErrorBudget(budget float64, window time.Duration) *ErrorBudget {
+	if budget <= 0 {
+		return nil
+	}
+	log.Printf("💸 Error budget: %.0f failures per %s", budget, window)
+	return &ErrorBudget{budget: budget, window: window, remaining: budget, last: time.Now()}
+}
+
+// Top up the budget for the time since the last update; the caller must hold b.mu
+func (b *ErrorBudget) refillLocked(now time.Time) {
+	b.remaining += b.budget * float64(now.Sub(b.last)) / float64(b.window)
+	if b.remaining > b.budget {
+		b.remaining = b.budget
+	}
+	b.last = now
+}
+
+// A full budget with the same settings, refilling from start; for replays
+func (b *ErrorBudget) fresh(start time.Time) *ErrorBudget {
+	if b == nil {
+		return nil
+	}
+	return &ErrorBudget{budget: b.budget, window: b.window, remaining: b.budget, last: start}
+}
+
+// Spend one unit for a failure at now and report whether the budget is exhausted
+func (b *ErrorBudget) consume(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refillLocked(now)
+	b.remaining--
+	if b.remaining <= 0 {
+		b.remaining = 0
+		return true
+	}
+	return false
+}
+
+func (b *ErrorBudget) stats() ErrorBudgetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refillLocked(time.Now())
+	return ErrorBudgetStats{Budget: b.budget, Window: b.window.String(), Remaining: b.remaining}
+}
+
+// Windowed outcome totals behind the sliding-window ratio rule
+type outcomeWindow interface {
+	counts(now time.Time) (successes, failures int)
+}
+
+// Totals already read elsewhere, e.g. from Redis
+type windowTotals struct {
+	successes, failures int
+}
+
+func (w windowTotals) counts(time.Time) (int, int) { return w.successes, w.failures }
+
+// The trip decision of the main breaker, shared by the live breaker, the
+// fleet-wide counts and the event replay so all three judge alike. An error
+// budget replaces the count rules; with a sliding window the ratio rule uses
+// the window while the consecutive-failure rule still uses the counts.
+// Without either it is readyToTrip. Only called on failures, like
+// gobreaker's ReadyToTrip, so each call spends budget.
+type TripPolicy struct {
+	budget *ErrorBudget
+	window outcomeWindow // nil without CB_SLIDING_WINDOW
+}
+
+// A nil window must stay a nil interface
+func newTripPolicy(budget *ErrorBudget, window *SlidingWindow) TripPolicy {
+	policy := TripPolicy{budget: budget}
+	if window != nil {
+		policy.window = window
+	}
+	return policy
+}
+
+var liveTripPolicy = newTripPolicy(errorBudget, slidingWindow)
+
+// The window totals must not include the failure being judged yet: gobreaker
+// asks before it is recorded there, so it is added here
+func (p TripPolicy) readyToTrip(counts gobreaker.Counts, now time.Time) bool {
+	if p.budget != nil {
+		return p.budget.consume(now)
+	}
+	if p.window != nil {
+		successes, failures := p.window.counts(now)
+		return counts.ConsecutiveFailures >= tripConsecutiveFailures || windowRatioTrips(successes, failures+1)
+	}
+	return readyToTrip(counts)
+}
+
+// ReadyToTrip for the live breaker
+func breakerTripPolicy(counts gobreaker.Counts) bool {
+	return liveTripPolicy.readyToTrip(counts, time.Now())
+}
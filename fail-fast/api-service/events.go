@@ -0,0 +1,210 @@
+// api-service/events.go
+// bounded breaker event log and an offline replay for post-incident analysis
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+const (
+	eventOutcome     = "outcome"
+	eventStateChange = "state_change"
+)
+
+// One recorded request outcome or breaker transition
+type BreakerEvent struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Success bool      `json:"success,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	From    string    `json:"from,omitempty"`
+	To      string    `json:"to,omitempty"`
+}
+
+// Ring buffer keeping the most recent events
+type EventRecorder struct {
+	events []BreakerEvent
+	next   int
+	full   bool
+	mu     sync.Mutex
+}
+
+var eventLog = newEventRecorder(getEnvInt("EVENT_LOG_SIZE", 1000))
+
+func newEventRecorder(size int) *EventRecorder {
+	return &EventRecorder{events: make([]BreakerEvent, max(size, 1))}
+}
+
+func (e *EventRecorder) record(event BreakerEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events[e.next] = event
+	e.next = (e.next + 1) % len(e.events)
+	if e.next == 0 {
+		e.full = true
+	}
+}
+
+// Outcomes of requests that actually ran through the breaker
+func (e *EventRecorder) recordOutcome(err error) {
+	event := BreakerEvent{Time: time.Now(), Type: eventOutcome, Success: isBreakerSuccess(err)}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	e.record(event)
+}
+
+func (e *EventRecorder) recordStateChange(from, to gobreaker.State) {
+	e.record(BreakerEvent{Time: time.Now(), Type: eventStateChange, From: from.String(), To: to.String()})
+}
+
+// Events oldest first
+func (e *EventRecorder) list() []BreakerEvent {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.full {
+		return append([]BreakerEvent(nil), e.events[:e.next]...)
+	}
+	return append(append([]BreakerEvent(nil), e.events[e.next:]...), e.events[:e.next]...)
+}
+
+// State of the replayed breaker after each outcome
+type ReplayStep struct {
+	Time     time.Time        `json:"time"`
+	Success  bool             `json:"success"`
+	Admitted bool             `json:"admitted"`
+	State    string           `json:"state"`
+	Counts   gobreaker.Counts `json:"counts"`
+	Note     string           `json:"note,omitempty"`
+}
+
+// Feed recorded outcomes through a fresh breaker with the live settings.
+// This mirrors gobreaker's state machine on the recorded timestamps, so
+// interval resets and open timeouts happen when they would have in real
+// time. Trips go through the live TripPolicy, with its own error budget and
+// sliding window advanced on the same timestamps. Outcomes the replayed
+// breaker would have rejected are marked as not admitted and don't affect
+// its counts.
+func replayEvents(events []BreakerEvent) []ReplayStep {
+	var (
+		state   = gobreaker.StateClosed
+		counts  gobreaker.Counts
+		expiry  time.Time
+		steps   []ReplayStep
+		started bool
+		policy  TripPolicy
+		window  = slidingWindow.fresh()
+	)
+
+	newGeneration := func(now time.Time) {
+		counts = gobreaker.Counts{}
+		switch state {
+		case gobreaker.StateClosed:
+			expiry = time.Time{}
+			if interval := closedInterval(); interval > 0 {
+				expiry = now.Add(interval)
+			}
+		case gobreaker.StateOpen:
+			expiry = now.Add(breakerTimeout)
+		default:
+			expiry = time.Time{}
+		}
+	}
+
+	for _, event := range events {
+		if event.Type != eventOutcome {
+			continue
+		}
+		now := event.Time
+		if !started {
+			newGeneration(now)
+			policy = newTripPolicy(errorBudget.fresh(now), window)
+			started = true
+		}
+
+		note := ""
+		stateBefore := state
+		if state == gobreaker.StateClosed && !expiry.IsZero() && now.After(expiry) {
+			newGeneration(now)
+			note = "interval reset"
+		}
+		if state == gobreaker.StateOpen && now.After(expiry) {
+			state = gobreaker.StateHalfOpen
+			newGeneration(now)
+			stateBefore = state
+			note = "timeout elapsed: open → half-open"
+		}
+
+		step := ReplayStep{Time: now, Success: event.Success}
+		switch {
+		case state == gobreaker.StateOpen:
+			note = "rejected: circuit open"
+		case state == gobreaker.StateHalfOpen && counts.Requests >= breakerMaxRequests:
+			note = "rejected: too many half-open probes"
+		case event.Success:
+			step.Admitted = true
+			counts.Requests++
+			counts.TotalSuccesses++
+			counts.ConsecutiveSuccesses++
+			counts.ConsecutiveFailures = 0
+			if state == gobreaker.StateHalfOpen && counts.ConsecutiveSuccesses >= breakerMaxRequests {
+				state = gobreaker.StateClosed
+				note = "probes succeeded: half-open → closed"
+			}
+		default:
+			step.Admitted = true
+			counts.Requests++
+			counts.TotalFailures++
+			counts.ConsecutiveFailures++
+			counts.ConsecutiveSuccesses = 0
+			if state == gobreaker.StateHalfOpen {
+				state = gobreaker.StateOpen
+				note = "probe failed: half-open → open"
+			} else if policy.readyToTrip(counts, now) {
+				state = gobreaker.StateOpen
+				note = "ReadyToTrip: closed → open"
+			}
+		}
+
+		// As live: the window forgets everything when the circuit opens,
+		// including the call that opened it
+		if window != nil && step.Admitted {
+			if state == gobreaker.StateOpen {
+				window.reset(now)
+			} else {
+				window.record(now, now, event.Success)
+			}
+		}
+
+		// Report the counts that drove this step, then start the new
+		// generation if the state changed
+		step.Counts = counts
+		step.State = state.String()
+		step.Note = note
+		if state != stateBefore {
+			newGeneration(now)
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// Export the event log; ?replay=true adds a replay through a fresh breaker
+func handleDebugEvents(w http.ResponseWriter, r *http.Request) {
+	events := eventLog.list()
+	response := struct {
+		Events []BreakerEvent `json:"events"`
+		Replay []ReplayStep   `json:"replay,omitempty"`
+	}{Events: events}
+
+	if replay, _ := strconv.ParseBool(r.URL.Query().Get("replay")); replay {
+		response.Replay = replayEvents(events)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,91 @@
+// api-service/expect_test.go
+// Expect: 100-continue checkouts refused or accepted from the headers
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Request body that records whether the client ever started sending it
+type watchedBody struct {
+	io.Reader
+	read atomic.Bool
+}
+
+func (b *watchedBody) Read(p []byte) (int, error) {
+	b.read.Store(true)
+	return b.Reader.Read(p)
+}
+
+func TestExpectContinue(t *testing.T) {
+	payment, _ := countingServer(t, http.StatusOK)
+	withPaymentService(t, payment.URL)
+	saved := maxBodyBytes
+	t.Cleanup(func() { maxBodyBytes = saved })
+	maxBodyBytes = 256
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/checkout", handleCheckout)
+	server := httptest.NewServer(withRequestID(withRecovery(mux)))
+	t.Cleanup(server.Close)
+
+	// Wait for the server's verdict rather than sending the body after
+	// the default 1s
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ExpectContinueTimeout = 5 * time.Second
+	t.Cleanup(transport.CloseIdleConnections)
+	client := &http.Client{Transport: transport}
+
+	valid := `{"item":"costume","price":10}`
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		continued   bool
+	}{
+		{"valid", "application/json", valid, http.StatusOK, true},
+		{"oversized", "application/json", `{"item":"` + strings.Repeat("x", 1024) + `","price":10}`, http.StatusRequestEntityTooLarge, false},
+		{"wrong content type", "text/plain", valid, http.StatusUnsupportedMediaType, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body := &watchedBody{Reader: strings.NewReader(tc.body)}
+			var continued atomic.Bool
+			trace := &httptrace.ClientTrace{Got100Continue: func() { continued.Store(true) }}
+
+			req, err := http.NewRequest(http.MethodPost, server.URL+"/api/checkout", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+			req.ContentLength = int64(len(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			req.Header.Set("Expect", "100-continue")
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.status {
+				t.Errorf("status %d, want %d", resp.StatusCode, tc.status)
+			}
+			if continued.Load() != tc.continued {
+				t.Errorf("got 100 Continue = %t, want %t", continued.Load(), tc.continued)
+			}
+			if body.read.Load() != tc.continued {
+				t.Errorf("body sent = %t, want %t", body.read.Load(), tc.continued)
+			}
+		})
+	}
+}
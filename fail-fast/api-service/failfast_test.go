@@ -0,0 +1,71 @@
+// api-service/failfast_test.go
+// open-circuit rejections against the full timeout they replace
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// Stub payment service that never answers until the test ends
+func hangingServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) }) // runs first, so Close doesn't wait on handlers
+	return server
+}
+
+func TestFastFailIsFasterThanTimeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits out the payment timeout")
+	}
+	withPaymentService(t, hangingServer(t).URL)
+	withRetries(t, 0, retryBackoff)
+
+	// Enough concurrent timeouts to trip the breaker, paid for once
+	latencies := make([]time.Duration, tripConsecutiveFailures)
+	var wg sync.WaitGroup
+	for i := range latencies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			start := time.Now()
+			if rec := checkout(http.MethodPost, nil); rec.Code == http.StatusOK {
+				t.Errorf("pre-trip checkout %d succeeded against a hanging downstream", i)
+			}
+			latencies[i] = time.Since(start)
+		}(i)
+	}
+	wg.Wait()
+	for i, latency := range latencies {
+		if latency < paymentTimeout || latency > paymentTimeout+time.Second {
+			t.Errorf("pre-trip checkout %d took %s, want about the %s payment timeout", i, latency, paymentTimeout)
+		}
+	}
+	if state := cb.State(); state != gobreaker.StateOpen {
+		t.Fatalf("breaker %s after %d timeouts, want open", state, tripConsecutiveFailures)
+	}
+
+	for i := 0; i < 10; i++ {
+		start := time.Now()
+		rec := checkout(http.MethodPost, nil)
+		if latency := time.Since(start); latency > 10*time.Millisecond {
+			t.Errorf("post-trip checkout %d took %s, want under 10ms", i, latency)
+		}
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("post-trip checkout %d: status %d, want 503", i, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// api-service/flags.go
+// runtime feature flags, toggled via /admin/flags without a restart
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Known flags
+const (
+	flagEnableFallback = "enable_fallback"
+	flagEnableRetries  = "enable_retries"
+	flagDegradedMode   = "degraded_mode"
+)
+
+var (
+	adminToken = getEnvString("ADMIN_TOKEN", "")
+
+	// Seeded from the environment; only known flags can be set
+	featureFlags = &FlagSet{flags: map[string]bool{
+		flagEnableFallback: getEnvBool("ENABLE_FALLBACK", false),
+		flagEnableRetries:  getEnvBool("ENABLE_RETRIES", true),
+		flagDegradedMode:   getEnvBool("DEGRADED_MODE", false),
+	}}
+)
+
+type FlagSet struct {
+	flags map[string]bool
+	mu    sync.RWMutex
+}
+
+func (f *FlagSet) enabled(name string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.flags[name]
+}
+
+func (f *FlagSet) all() map[string]bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	copied := make(map[string]bool, len(f.flags))
+	for name, value := range f.flags {
+		copied[name] = value
+	}
+	return copied
+}
+
+// Apply all updates or none; unknown flag names are rejected
+func (f *FlagSet) update(updates map[string]bool) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var unknown []string
+	for name := range updates {
+		if _, ok := f.flags[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return unknown
+	}
+	for name, value := range updates {
+		f.flags[name] = value
+		log.Printf("🚩 Flag %s = %t", name, value)
+	}
+	return nil
+}
+
+// GET lists the flags; POST {"flag": bool, ...} updates them and requires
+// "Authorization: Bearer $ADMIN_TOKEN" (disabled when ADMIN_TOKEN is unset)
+func handleAdminFlags(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, featureFlags.all())
+	case http.MethodPost:
+		if !isAdmin(r) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "Admin token required"})
+			return
+		}
+		var updates map[string]bool
+		if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Expected a JSON object of flag names to booleans"})
+			return
+		}
+		if unknown := featureFlags.update(updates); unknown != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error": "Unknown flags", "flags": unknown})
+			return
+		}
+		writeJSON(w, http.StatusOK, featureFlags.all())
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func isAdmin(r *http.Request) bool {
+	if adminToken == "" {
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+// Degraded success: accept the order now and settle the payment later
+func fallbackResult(reason string, latency time.Duration) CheckoutResult {
+	return CheckoutResult{http.StatusAccepted, map[string]interface{}{
+		"status":   "pending",
+		"fallback": true,
+		"message":  "Order accepted, payment will be processed shortly",
+		"reason":   reason,
+		"latency":  latency.String(),
+	}}
+}
@@ -0,0 +1,55 @@
+// api-service/generation.go
+// mirror of gobreaker's private generation counter for the main breaker
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+var breakerGeneration = newGenerationTracker()
+
+// gobreaker starts a new generation (and zeroes its counts) on every state
+// change and, while closed, on the first observation after the interval has
+// expired. It doesn't expose the number, so this follows the same rules:
+// stateChanged from OnStateChange and observe wherever the state is read.
+// Under CB_SLIDING_WINDOW the main breaker has no interval (closedInterval),
+// so only state changes count.
+type GenerationTracker struct {
+	generation uint64
+	expiry     time.Time // zero while open or half-open, or with no interval: no resets
+	mu         sync.Mutex
+}
+
+func newGenerationTracker() *GenerationTracker {
+	g := &GenerationTracker{}
+	if interval := closedInterval(); interval > 0 {
+		g.expiry = time.Now().Add(interval)
+	}
+	return g
+}
+
+// Called from OnStateChange, i.e. under the breaker's lock: must not read cb
+func (g *GenerationTracker) stateChanged(to gobreaker.State) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.generation++
+	g.expiry = time.Time{}
+	if interval := closedInterval(); to == gobreaker.StateClosed && interval > 0 {
+		g.expiry = time.Now().Add(interval)
+	}
+}
+
+// Account for an interval reset the breaker would have made by now
+func (g *GenerationTracker) observe(state gobreaker.State) uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	if state == gobreaker.StateClosed && !g.expiry.IsZero() && !now.Before(g.expiry) {
+		g.generation++
+		g.expiry = now.Add(closedInterval())
+	}
+	return g.generation
+}
@@ -0,0 +1,172 @@
+// api-service/grpcserver.go
+// optional gRPC front end for checkout, sharing the HTTP path's breaker and metrics
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// gRPC listens on GRPC_PORT when set; 0 keeps checkout HTTP-only
+var grpcPort = getEnvInt("GRPC_PORT", 0)
+
+// Messages are JSON rather than protobuf, so there is no protoc step: the
+// request is a CheckoutRequest and the reply the HTTP success body. Clients
+// call /checkout.CheckoutService/Checkout with content-subtype "json".
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return "json" }
+
+var checkoutServiceDesc = grpc.ServiceDesc{
+	ServiceName: "checkout.CheckoutService",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Checkout",
+		Handler:    grpcCheckoutHandler,
+	}},
+	Metadata: "checkout.proto",
+}
+
+// Request metadata forwarded as the headers processCheckout reads
+var grpcForwardedHeaders = []string{"X-Tenant-ID", "X-Dry-Run", "X-Request-ID", "Idempotency-Key"}
+
+func startGRPCServer() {
+	if grpcPort <= 0 {
+		return
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
+	if err != nil {
+		log.Fatalf("❌ Could not listen on GRPC_PORT %d: %v", grpcPort, err)
+	}
+	server := grpc.NewServer(grpc.ForceServerCodec(jsonCodec{}), grpc.UnaryInterceptor(grpcRecovery))
+	server.RegisterService(&checkoutServiceDesc, nil)
+
+	go func() {
+		<-shutdownCtx.Done()
+		server.GracefulStop()
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Printf("⚠️ gRPC server stopped: %v", err)
+		}
+	}()
+	log.Printf("📞 gRPC checkout on :%d (checkout.CheckoutService/Checkout, JSON codec)", grpcPort)
+}
+
+func grpcCheckoutHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	var req CheckoutRequest
+	if err := dec(&req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid request format")
+	}
+	if interceptor == nil {
+		return grpcCheckout(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/checkout.CheckoutService/Checkout"}
+	return interceptor(ctx, &req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return grpcCheckout(ctx, *req.(*CheckoutRequest))
+	})
+}
+
+// gRPC calls bypass withRecovery, so a panic is turned into codes.Internal
+// here rather than taking the process down
+func grpcRecovery(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("💥 PANIC in gRPC %s: %v\n%s", info.FullMethod, rec, debug.Stack())
+			recordPanic()
+			err = status.Error(codes.Internal, "Internal server error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// Run a gRPC checkout through the same worker pool, breaker and metrics as
+// POST /api/checkout, then map the HTTP-style result to a gRPC status
+func grpcCheckout(ctx context.Context, req CheckoutRequest) (interface{}, error) {
+	start := time.Now()
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/api/checkout", nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, header := range grpcForwardedHeaders {
+		if values := md.Get(header); len(values) > 0 {
+			r.Header.Set(header, values[0])
+		}
+	}
+	// The call deadline becomes the X-Timeout-Ms budget
+	if deadline, ok := ctx.Deadline(); ok {
+		r.Header.Set("X-Timeout-Ms", strconv.FormatInt(max(time.Until(deadline).Milliseconds(), 1), 10))
+	}
+	id := clientRequestID(r.Header.Get("X-Request-ID"))
+	r = r.WithContext(context.WithValue(r.Context(), requestIDKey, id))
+
+	// Same Idempotency-Key replay as the HTTP handler
+	key := idempotencyKey(r)
+	result, replayed := CheckoutResult{}, false
+	if key != "" {
+		result, replayed = idempotencyStore.get(key)
+	}
+	if replayed {
+		log.Printf("🔁 IDEMPOTENT REPLAY [%s] (gRPC)", id)
+	} else {
+		result = runCheckout(r, req, start)
+		logSlowCheckout(r, req, result.Status, time.Since(start))
+		if key != "" {
+			idempotencyStore.put(key, result)
+		}
+	}
+	if result.Status < http.StatusBadRequest {
+		return result.Body, nil
+	}
+	return nil, status.Error(grpcCode(result.Status), resultError(result))
+}
+
+// HTTP status → gRPC code, following the gRPC HTTP mapping where one exists
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusRequestEntityTooLarge:
+		return codes.InvalidArgument
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusPaymentRequired:
+		return codes.FailedPrecondition
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case statusClientClosedRequest:
+		return codes.Canceled
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// The "error" field of a failed result's body, for the gRPC status message
+func resultError(result CheckoutResult) string {
+	switch body := result.Body.(type) {
+	case map[string]string:
+		return body["error"]
+	case map[string]interface{}:
+		if message, ok := body["error"].(string); ok {
+			return message
+		}
+	}
+	return http.StatusText(result.Status)
+}
@@ -0,0 +1,52 @@
+// api-service/grpcserver_test.go
+// gRPC checkouts go through the server's interceptor and survive panics
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCRecoveryReturnsInternal(t *testing.T) {
+	metrics.mu.Lock()
+	before := metrics.Panics
+	metrics.mu.Unlock()
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/checkout.CheckoutService/Checkout"}
+	_, err := grpcRecovery(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		panic("boom")
+	})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("got %v, want codes.Internal", err)
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	if metrics.Panics != before+1 {
+		t.Errorf("panics went from %d to %d, want one more", before, metrics.Panics)
+	}
+}
+
+func TestGRPCCheckoutHandlerCallsInterceptor(t *testing.T) {
+	dec := func(v interface{}) error {
+		v.(*CheckoutRequest).Item = "costume"
+		return nil
+	}
+	var seen *CheckoutRequest
+	interceptor := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		seen = req.(*CheckoutRequest)
+		return "intercepted", nil
+	}
+
+	resp, err := grpcCheckoutHandler(nil, context.Background(), dec, interceptor)
+	if err != nil || resp != "intercepted" {
+		t.Fatalf("got %v, %v; want the interceptor's reply", resp, err)
+	}
+	if seen == nil || seen.Item != "costume" {
+		t.Errorf("interceptor saw %+v, want the decoded request", seen)
+	}
+}
@@ -0,0 +1,47 @@
+// api-service/h2c_test.go
+// checkout over cleartext HTTP/2 with ENABLE_H2C
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/http2"
+)
+
+func TestCheckoutOverH2C(t *testing.T) {
+	payment, _ := countingServer(t, http.StatusOK)
+	withPaymentService(t, payment.URL)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/checkout", handleCheckout)
+	server := httptest.NewServer(withH2C(withRequestID(withRecovery(mux))))
+	t.Cleanup(server.Close)
+
+	// Prior-knowledge h2c: speak HTTP/2 over plain TCP, no TLS and no upgrade
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}}
+	resp, err := client.Post(server.URL+"/api/checkout", "application/json",
+		strings.NewReader(`{"item":"costume","price":10}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.ProtoMajor != 2 {
+		t.Errorf("served over %s, want HTTP/2", resp.Proto)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("checkout status %d, want 200", resp.StatusCode)
+	}
+}
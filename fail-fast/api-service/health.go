@@ -0,0 +1,185 @@
+// api-service/health.go
+// active downstream health probing that backs the /ready endpoint
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+// Recent success rate (percent, over RECENT_WINDOW) below which /ready
+// reports not-ready; 0 disables the check
+var readyMinSuccessRate = getEnvFloat("READY_MIN_SUCCESS_RATE", 0)
+
+// After startup /ready stays not-ready for CB_WARMUP so the breaker doesn't
+// take production traffic before it has baseline behavior; 0 disables
+var breakerWarmup = getEnvDuration("CB_WARMUP", 0)
+
+// Last readiness reported by /ready, so only transitions are logged
+var lastReady atomic.Bool
+
+// Cached result of the most recent downstream probe
+type HealthStatus struct {
+	Checked   bool
+	Healthy   bool
+	LastCheck time.Time
+	LastError string
+	mu        sync.RWMutex
+}
+
+var (
+	downstreamHealth = &HealthStatus{}
+	probeCB          *gobreaker.CircuitBreaker
+)
+
+// Separate breaker for the health probe so a down dependency isn't polled
+// at full rate while it recovers; independent of the checkout breaker
+func newProbeBreaker() *gobreaker.CircuitBreaker {
+	return gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:        "health-probe",
+		MaxRequests: 1,
+		Timeout:     getEnvDuration("HEALTH_PROBE_CB_TIMEOUT", 30*time.Second),
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= 3
+		},
+		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+			log.Printf("🩺 PROBE STATE CHANGE: %s → %s", from, to)
+		},
+	})
+}
+
+// Poll the downstream /health in the background so /ready never blocks on it
+func startHealthChecker(baseURL string, interval time.Duration) {
+	log.Printf("🩺 Probing %s/health every %s", baseURL, interval)
+	probeCB = newProbeBreaker()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			_, err := probeCB.Execute(func() (interface{}, error) {
+				return nil, probeDownstream(baseURL)
+			})
+			downstreamHealth.record(err)
+			<-ticker.C
+		}
+	}()
+}
+
+func probeDownstream(baseURL string) error {
+	client := &http.Client{Timeout: healthCheckTimeout}
+	resp, err := client.Get(baseURL + "/health")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check failed (%d: %s)", resp.StatusCode, resp.Status)
+	}
+	return nil
+}
+
+func (h *HealthStatus) record(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	healthy := err == nil
+	if h.Checked && h.Healthy != healthy {
+		if healthy {
+			log.Println("🩺 Downstream recovered")
+		} else {
+			log.Printf("🩺 Downstream unhealthy: %v", err)
+		}
+	}
+
+	h.Checked = true
+	h.Healthy = healthy
+	h.LastCheck = time.Now()
+	h.LastError = ""
+	if err != nil {
+		h.LastError = err.Error()
+	}
+}
+
+func (h *HealthStatus) snapshot() (checked, healthy bool, lastCheck time.Time, lastError string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.Checked, h.Healthy, h.LastCheck, h.LastError
+}
+
+// True only once a probe has completed and found the downstream down
+func (h *HealthStatus) knownDown() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.Checked && !h.Healthy
+}
+
+// Readiness reads only the cached probe result; before the first probe
+// completes the service reports not-ready
+func handleReady(w http.ResponseWriter, r *http.Request) {
+	checked, healthy, lastCheck, lastError := downstreamHealth.snapshot()
+
+	response := struct {
+		Ready             bool            `json:"ready"`
+		DownstreamHealthy bool            `json:"downstream_healthy"`
+		ProbeCircuitState gobreaker.State `json:"probe_circuit_state"`
+		LastCheck         string          `json:"last_check,omitempty"`
+		LastError         string          `json:"last_error,omitempty"`
+		RecentSuccessRate *float64        `json:"recent_success_rate,omitempty"`
+		MinSuccessRate    float64         `json:"min_success_rate,omitempty"`
+		WarmingUp         bool            `json:"warming_up,omitempty"`
+		WarmupRemaining   string          `json:"warmup_remaining,omitempty"`
+	}{
+		Ready:             checked && healthy,
+		MinSuccessRate:    readyMinSuccessRate,
+		DownstreamHealthy: healthy,
+		ProbeCircuitState: probeCB.State(),
+		LastError:         lastError,
+	}
+	if checked {
+		response.LastCheck = lastCheck.Format(time.RFC3339)
+	} else {
+		response.LastError = "no health check completed yet"
+	}
+
+	// Pull the instance out of rotation during sustained failures even when
+	// the dependency answers its probe; with no recent traffic it stays ready
+	if readyMinSuccessRate > 0 {
+		metrics.mu.Lock()
+		rate, samples := metrics.Recent.successRate(time.Now(), recentWindow)
+		metrics.mu.Unlock()
+		if samples > 0 {
+			response.RecentSuccessRate = &rate
+			if rate < readyMinSuccessRate {
+				response.Ready = false
+			}
+		}
+	}
+
+	if remaining := breakerWarmup - time.Since(startedAt); remaining > 0 {
+		response.Ready = false
+		response.WarmingUp = true
+		response.WarmupRemaining = remaining.Round(time.Second).String()
+	}
+
+	if lastReady.Swap(response.Ready) != response.Ready {
+		if response.Ready {
+			log.Println("✅ Instance is ready for traffic")
+		} else {
+			log.Println("⛔ Instance is no longer ready")
+		}
+	}
+
+	status := http.StatusOK
+	if !response.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, response)
+}
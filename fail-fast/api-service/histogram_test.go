@@ -0,0 +1,70 @@
+// api-service/histogram_test.go
+// HDR histogram percentiles against the raw-sample method
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Log-normal latencies around a 50ms median with a long tail, as a
+// struggling downstream produces
+func logNormalLatencies(n int, seed int64) []time.Duration {
+	rng := rand.New(rand.NewSource(seed))
+	latencies := make([]time.Duration, n)
+	for i := range latencies {
+		latencies[i] = time.Duration(math.Exp(math.Log(50e6)+rng.NormFloat64()) * float64(time.Nanosecond))
+	}
+	return latencies
+}
+
+func TestHistogramPercentilesMatchRaw(t *testing.T) {
+	defer func(method string) { percentileMethod = method }(percentileMethod)
+	percentileMethod = percentileNearestRank
+
+	raw := &LatencySamples{}
+	hist := &LatencySamples{hist: hdrhistogram.New(histogramMin, histogramMax, 3)}
+	for _, latency := range logNormalLatencies(10000, 1) {
+		raw.add(latency)
+		hist.add(latency)
+	}
+
+	fractions := []float64{0.50, 0.90, 0.95, 0.99, 0.999}
+	want := raw.percentiles(fractions...)
+	got := hist.percentiles(fractions...)
+	for i, fraction := range fractions {
+		// 3 significant figures is 0.1%; samples are also truncated to whole
+		// microseconds when recorded
+		tolerance := time.Duration(float64(want[i])*0.001) + time.Microsecond
+		if diff := got[i] - want[i]; diff < -tolerance || diff > tolerance {
+			t.Errorf("p%g: histogram %s, raw %s (tolerance %s)", fraction*100, got[i], want[i], tolerance)
+		}
+	}
+}
+
+func TestHistogramModeKeepsNoRawSamples(t *testing.T) {
+	hist := &LatencySamples{hist: hdrhistogram.New(histogramMin, histogramMax, 3)}
+	before := hist.hist.ByteSize()
+	for _, latency := range logNormalLatencies(10000, 2) {
+		hist.add(latency)
+	}
+
+	if hist.samples() != nil {
+		t.Errorf("histogram mode kept %d raw samples", len(hist.samples()))
+	}
+	if hist.count() != 10000 {
+		t.Errorf("count = %d, want 10000", hist.count())
+	}
+	if after := hist.hist.ByteSize(); after != before {
+		t.Errorf("histogram grew from %d to %d bytes", before, after)
+	}
+
+	hist.reset()
+	if hist.count() != 0 {
+		t.Errorf("count after reset = %d, want 0", hist.count())
+	}
+}
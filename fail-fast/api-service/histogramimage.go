@@ -0,0 +1,134 @@
+// api-service/histogramimage.go
+// on-demand PNG of the latency distribution for reports
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	histogramWidth   = 800
+	histogramHeight  = 400
+	histogramBuckets = 20
+	histogramMargin  = 50
+)
+
+var (
+	histogramBackground = color.White
+	histogramBar        = color.RGBA{0x42, 0x85, 0xf4, 0xff}
+	histogramInk        = color.Black
+)
+
+// Serve /metrics/histogram.png when ENABLE_HISTOGRAM_PNG=true
+func registerHistogramImage() {
+	if !getEnvBool("ENABLE_HISTOGRAM_PNG", false) {
+		return
+	}
+	handleRoute("/metrics/histogram.png", []string{http.MethodGet}, "Latency histogram as a PNG", handleHistogramImage)
+	log.Println("🖼️ Latency histogram available at /metrics/histogram.png")
+}
+
+func handleHistogramImage(w http.ResponseWriter, r *http.Request) {
+	metrics.mu.Lock()
+	latencies := append([]time.Duration(nil), metrics.Latencies.samples()...)
+	rawRecorded := metrics.Latencies.hist == nil
+	metrics.mu.Unlock()
+
+	if !rawRecorded {
+		writeText(w, http.StatusConflict, "Raw latencies are not kept while LATENCY_HISTOGRAM is enabled")
+		return
+	}
+	if len(latencies) == 0 {
+		writeText(w, http.StatusNotFound, "No latency data yet")
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, renderHistogram(latencies)); err != nil {
+		log.Printf("⚠️ Could not encode latency histogram: %v", err)
+		writeText(w, http.StatusInternalServerError, "Could not render histogram")
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(buf.Bytes())
+}
+
+// Equal-width buckets from the fastest to the slowest sample, with the
+// bucket range under each bar and its count above it
+func renderHistogram(latencies []time.Duration) image.Image {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	lo, hi := latencies[0], latencies[len(latencies)-1]
+	width := (hi - lo) / histogramBuckets
+	if width <= 0 {
+		width = 1
+	}
+
+	counts := make([]int, histogramBuckets)
+	peak := 0
+	for _, latency := range latencies {
+		bucket := min(int((latency-lo)/width), histogramBuckets-1)
+		counts[bucket]++
+		peak = max(peak, counts[bucket])
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, histogramWidth, histogramHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{histogramBackground}, image.Point{}, draw.Src)
+
+	plotHeight := histogramHeight - 2*histogramMargin
+	barWidth := (histogramWidth - 2*histogramMargin) / histogramBuckets
+	baseline := histogramHeight - histogramMargin
+	for i, count := range counts {
+		x := histogramMargin + i*barWidth
+		height := count * plotHeight / peak
+		bar := image.Rect(x+1, baseline-height, x+barWidth-1, baseline)
+		draw.Draw(img, bar, &image.Uniform{histogramBar}, image.Point{}, draw.Src)
+		if count > 0 {
+			drawLabel(img, x+2, baseline-height-4, fmt.Sprint(count))
+		}
+		// Label every other bucket so the start values don't overlap
+		if i%2 == 0 {
+			drawLabel(img, x, baseline+15, shortDuration(lo+time.Duration(i)*width))
+		}
+	}
+	drawLabel(img, histogramWidth-histogramMargin-40, baseline+30, shortDuration(hi))
+	drawLabel(img, histogramMargin, histogramMargin/2,
+		fmt.Sprintf("Checkout latency distribution (n=%d, %s to %s)", len(latencies), shortDuration(lo), shortDuration(hi)))
+	return img
+}
+
+func drawLabel(img draw.Image, x, y int, text string) {
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(histogramInk),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+// Rounded duration; "us" because the built-in font is ASCII-only
+func shortDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		d = d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		d = d.Round(100 * time.Microsecond)
+	default:
+		d = d.Round(time.Microsecond)
+	}
+	return strings.Replace(d.String(), "µs", "us", 1)
+}
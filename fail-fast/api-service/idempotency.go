@@ -0,0 +1,126 @@
+// api-service/idempotency.go
+// Idempotency-Key support: replay the stored result for retried checkouts
+package main
+
+import (
+	"container/list"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var idempotencyStore = newIdempotencyStore(
+	getEnvInt("IDEMPOTENCY_MAX_KEYS", 10000),
+	getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+)
+
+// LRU of completed checkout results, bounded by entry count and age;
+// whichever limit is hit first evicts
+type IdempotencyStore struct {
+	maxKeys int
+	ttl     time.Duration
+	order   *list.List // front = most recently used
+	entries map[string]*list.Element
+	hits    int
+	misses  int
+	mu      sync.Mutex
+}
+
+type idempotencyEntry struct {
+	key      string
+	result   CheckoutResult
+	storedAt time.Time
+}
+
+// Store state as reported in /metrics
+type IdempotencyStats struct {
+	Size    int `json:"size"`
+	MaxKeys int `json:"max_keys"`
+	Hits    int `json:"hits"`
+	Misses  int `json:"misses"`
+}
+
+func newIdempotencyStore(maxKeys int, ttl time.Duration) *IdempotencyStore {
+	if maxKeys < 1 {
+		log.Printf("⚠️ IDEMPOTENCY_MAX_KEYS must be at least 1, using 1")
+		maxKeys = 1
+	}
+	return &IdempotencyStore{
+		maxKeys: maxKeys,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// Keys are scoped per tenant so tenants can't collide
+func idempotencyKey(r *http.Request) string {
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" {
+		return ""
+	}
+	return r.Header.Get("X-Tenant-ID") + "/" + key
+}
+
+func (s *IdempotencyStore) get(key string) (CheckoutResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if ok && time.Since(elem.Value.(*idempotencyEntry).storedAt) > s.ttl {
+		s.removeLocked(elem)
+		ok = false
+	}
+	if !ok {
+		s.misses++
+		return CheckoutResult{}, false
+	}
+	s.hits++
+	s.order.MoveToFront(elem)
+	return elem.Value.(*idempotencyEntry).result, true
+}
+
+// Remember a final result. Server-side failures aren't stored so the
+// client's retry gets a real second attempt.
+func (s *IdempotencyStore) put(key string, result CheckoutResult) {
+	if result.Status >= http.StatusInternalServerError {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		s.removeLocked(elem)
+	}
+	s.entries[key] = s.order.PushFront(&idempotencyEntry{key: key, result: result, storedAt: time.Now()})
+
+	// Expired entries collect at the back; drop those first, then trim to size
+	for back := s.order.Back(); back != nil && time.Since(back.Value.(*idempotencyEntry).storedAt) > s.ttl; back = s.order.Back() {
+		s.removeLocked(back)
+	}
+	for s.order.Len() > s.maxKeys {
+		s.removeLocked(s.order.Back())
+	}
+}
+
+// The caller must hold s.mu
+func (s *IdempotencyStore) removeLocked(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.entries, elem.Value.(*idempotencyEntry).key)
+}
+
+// Zero hits and misses along with the other /metrics counters; stored
+// results are kept
+func (s *IdempotencyStore) resetCounters() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hits = 0
+	s.misses = 0
+}
+
+func (s *IdempotencyStore) stats() IdempotencyStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return IdempotencyStats{Size: s.order.Len(), MaxKeys: s.maxKeys, Hits: s.hits, Misses: s.misses}
+}
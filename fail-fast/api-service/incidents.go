@@ -0,0 +1,64 @@
+// api-service/incidents.go
+// per-incident metrics: archive and reset the live counters each time the circuit trips
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// Archived metrics for the period that ended when the circuit opened
+type Incident struct {
+	PeriodStart time.Time       `json:"period_start"`
+	TrippedAt   time.Time       `json:"tripped_at"`
+	Metrics     MetricsSnapshot `json:"metrics"`
+}
+
+var (
+	resetMetricsOnTrip = getEnvBool("RESET_METRICS_ON_TRIP", false)
+	maxIncidents       = getEnvInt("MAX_INCIDENTS", 20)
+
+	incidents   []Incident
+	incidentsMu sync.Mutex
+)
+
+// Snapshot the live metrics into the incident list and zero them. Called
+// from OnStateChange, i.e. while the breaker holds its own lock, so it must
+// not call back into the breaker.
+func archiveIncident() {
+	metrics.mu.Lock()
+	incident := Incident{
+		PeriodStart: metrics.Since,
+		TrippedAt:   time.Now(),
+		Metrics:     metrics.snapshotLocked(gobreaker.StateOpen, gobreaker.Counts{}),
+	}
+	metrics.resetLocked()
+	metrics.mu.Unlock()
+
+	incidentsMu.Lock()
+	defer incidentsMu.Unlock()
+	incidents = append(incidents, incident)
+	if len(incidents) > maxIncidents {
+		incidents = incidents[len(incidents)-maxIncidents:]
+	}
+	log.Printf("🗂️ Archived incident metrics (%d requests) and reset live counters", incident.Metrics.TotalRequests)
+}
+
+func handleIncidents(w http.ResponseWriter, r *http.Request) {
+	incidentsMu.Lock()
+	archived := make([]Incident, len(incidents))
+	copy(archived, incidents)
+	incidentsMu.Unlock()
+
+	writeJSON(w, http.StatusOK, struct {
+		Enabled   bool       `json:"enabled"`
+		Incidents []Incident `json:"incidents"`
+	}{
+		Enabled:   resetMetricsOnTrip,
+		Incidents: archived,
+	})
+}
@@ -0,0 +1,60 @@
+// api-service/influx.go
+// metrics export in InfluxDB line protocol for Telegraf or direct writes
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var influxMeasurement = getInfluxMeasurement()
+
+func getInfluxMeasurement() string {
+	return getEnvString("INFLUX_MEASUREMENT", "checkout")
+}
+
+func handleInfluxMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, influxLine(currentSnapshot(), time.Now()))
+}
+
+// Render a snapshot as one line: measurement,tags fields timestamp.
+// Latencies are float milliseconds; integer fields carry the "i" suffix.
+func influxLine(s MetricsSnapshot, ts time.Time) string {
+	fields := []string{
+		fmt.Sprintf("requests=%di", s.TotalRequests),
+		fmt.Sprintf("successes=%di", s.SuccessCount),
+		fmt.Sprintf("failures=%di", s.FailureCount),
+		fmt.Sprintf("fast_fails=%di", s.FastFails),
+		fmt.Sprintf("panics=%di", s.Panics),
+		fmt.Sprintf("success_rate=%g", s.SuccessRate),
+		fmt.Sprintf("error_rate=%g", s.ErrorRate),
+		fmt.Sprintf("circuit_state=%di", int(s.CircuitState)),
+	}
+	if !s.NoData {
+		fields = append(fields,
+			fmt.Sprintf("avg_latency_ms=%g", millis(s.avgLatency)),
+			fmt.Sprintf("p50=%g", millis(s.p50)),
+			fmt.Sprintf("p95=%g", millis(s.p95)),
+			fmt.Sprintf("p99=%g", millis(s.p99)),
+		)
+	}
+
+	tags := "service=api,circuit=" + escapeInfluxKey(s.CircuitState.String())
+	if s.Environment != "" {
+		tags += ",environment=" + escapeInfluxKey(s.Environment)
+	}
+	return fmt.Sprintf("%s,%s %s %d",
+		escapeInfluxKey(influxMeasurement), tags, strings.Join(fields, ","), ts.UnixNano())
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Escape commas, spaces and equals signs in measurement names and tag values
+func escapeInfluxKey(s string) string {
+	return strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`).Replace(s)
+}
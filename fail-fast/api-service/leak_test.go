@@ -0,0 +1,105 @@
+// api-service/leak_test.go
+// goroutines and pooled connections after many downstream calls
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Slow stub payment service cycling through a success, a 500 and a 200
+// that reports failure, so every body-handling path in doPaymentRequest runs
+func slowMixedServer(t *testing.T, delay time.Duration) *httptest.Server {
+	t.Helper()
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		switch calls.Add(1) % 3 {
+		case 0:
+			w.Write([]byte(`{"success": true}`))
+		case 1:
+			http.Error(w, "payment failed", http.StatusInternalServerError)
+		case 2:
+			w.Write([]byte(`{"success": false, "reason": "card declined"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Closing idle connections only frees connections whose bodies were fully
+// read and closed; a leaked body keeps its connection's goroutines alive
+func waitForGoroutines(t *testing.T, baseline int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		paymentClient.CloseIdleConnections()
+		current := runtime.NumGoroutine()
+		if current <= baseline {
+			return
+		}
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("%d goroutines, baseline %d\n%s", current, baseline, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNoGoroutinesLeftAfterSlowBurst(t *testing.T) {
+	server := slowMixedServer(t, 100*time.Millisecond)
+	withPaymentService(t, server.URL)
+	withRetries(t, 0, retryBackoff)
+
+	paymentClient.CloseIdleConnections()
+	baseline := runtime.NumGoroutine()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkout(http.MethodPost, nil)
+		}()
+	}
+	wg.Wait()
+
+	waitForGoroutines(t, baseline)
+}
+
+// Non-200 replies must be drained and closed so their connection goes back
+// to the pool: 200 failing calls in a row should all share one connection.
+// The stub's body matters; an empty one frees the connection regardless.
+func TestFailingCallsReuseConnections(t *testing.T) {
+	server, calls := countingServer(t, http.StatusServiceUnavailable)
+	paymentClient.CloseIdleConnections()
+
+	var dialed, reused int
+	trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+		if info.Reused {
+			reused++
+		} else {
+			dialed++
+		}
+	}}
+	ctx := httptrace.WithClientTrace(context.Background(), trace)
+
+	for i := 0; i < 200; i++ {
+		if _, err := doPaymentRequest(ctx, server.URL, 10); err == nil {
+			t.Fatal("expected the 503 to be returned as an error")
+		}
+	}
+	if calls.Load() != 200 {
+		t.Fatalf("downstream calls = %d, want 200", calls.Load())
+	}
+	if dialed != 1 || reused != 199 {
+		t.Errorf("%d new connections and %d reused, want 1 and 199", dialed, reused)
+	}
+}
@@ -0,0 +1,50 @@
+// api-service/loadsignal.go
+// proactive tripping on the downstream's X-Load health hint
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Load (as reported in X-Load, 1.0 = at capacity) above which a successful
+// reply still counts as a breaker failure; 0 disables the signal
+var loadThreshold = getLoadThreshold()
+
+func getLoadThreshold() float64 {
+	threshold := getEnvFloat("CB_LOAD_THRESHOLD", 0)
+	if threshold > 0 {
+		log.Printf("📈 Counting replies with X-Load above %.2f as breaker failures", threshold)
+	}
+	return threshold
+}
+
+// A successful reply from a downstream reporting itself overloaded. It is
+// returned alongside the response so the breaker sees a failure while the
+// checkout still succeeds.
+type DegradedError struct {
+	Load float64
+}
+
+func (e *DegradedError) Error() string {
+	return fmt.Sprintf("downstream degraded (load %.2f > %.2f)", e.Load, loadThreshold)
+}
+
+func checkLoadSignal(resp *http.Response) error {
+	if loadThreshold <= 0 {
+		return nil
+	}
+	load, err := strconv.ParseFloat(resp.Header.Get("X-Load"), 64)
+	if err != nil || load <= loadThreshold {
+		return nil
+	}
+	return &DegradedError{Load: load}
+}
+
+func isDegraded(err error) bool {
+	var degraded *DegradedError
+	return errors.As(err, &degraded)
+}
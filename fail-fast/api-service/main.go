@@ -3,21 +3,39 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"net"
 	"net/http"
-	"os"
-	"sort"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/sony/gobreaker"
+	"golang.org/x/net/netutil"
 )
 
 type CheckoutRequest struct {
-	Item  string  `json:"item"`
-	Price float64 `json:"price"`
+	Item     string  `json:"item"`
+	Price    float64 `json:"price"`
+	Currency string  `json:"currency,omitempty"`
+	Quantity *int    `json:"quantity,omitempty"` // unit count; 1 when omitted
+}
+
+// Units per checkout, as set by the client (default 1)
+func (req CheckoutRequest) quantity() int {
+	if req.Quantity == nil {
+		return 1
+	}
+	return *req.Quantity
 }
 
 type Metrics struct {
@@ -25,248 +43,1441 @@ type Metrics struct {
 	SuccessfulRequests int
 	FailedRequests     int
 	CircuitOpenRejects int
+	Panics             int
+	DryRuns            int
+	DegradedFallbacks  int // answered by degraded mode without a downstream call
+	Coalesced          int // shared another checkout's payment call
+	FailOpenResponses  int // open-circuit rejections answered with a degraded 200
+	Declined           int
+	ConnectionErrors   int // downstream unreachable
+	TimeoutErrors      int // downstream too slow
+	HTTP5xxErrors      int // downstream answered with a 5xx
+	CircuitTrips       int // cumulative; survives resetLocked
+	SkewedSamples      int // non-positive latencies, clamped to zero
 	TotalLatency       time.Duration
-	LatencyHistory     []time.Duration
+	TotalRetries       int
+	DownstreamRequests int
+	DownstreamLatency  time.Duration
+	Latencies          *LatencySamples            // every checkout, behind the headline percentiles
+	OutcomeLatency     map[string]*LatencySamples // keyed by outcome category
+	Recent             *RecentWindow
+	RecentScratch      *LatencySamples  // refilled from Recent for each windowed percentile
+	Baseline           *MetricsBaseline // nil until POST /metrics/baseline
+	Cohorts            map[string]*CohortMetrics
+	Priorities         map[string]*CohortMetrics // keyed by X-Priority class
+	Since              time.Time
 	mu                 sync.Mutex
 }
 
 var (
-	metrics = &Metrics{}
-	cb      *gobreaker.CircuitBreaker
+	metrics          = &Metrics{Latencies: newLatencySamples(), Recent: newRecentWindow(recentWindow, scaleSignalWindow), RecentScratch: newLatencySamples(), OutcomeLatency: map[string]*LatencySamples{}, Cohorts: map[string]*CohortMetrics{}, Priorities: map[string]*CohortMetrics{}, Since: time.Now()}
+	cb               *gobreaker.CircuitBreaker
+	percentileMethod = getPercentileMethod()
+	trimPercent      = getTrimPercent()
+	extraPercentiles = getPercentiles()
+	processingDelay  = getEnvDuration("PROCESSING_DELAY", 0)
+	flakyServiceURL  = getFlakyServiceURL()
+	maxQuantity      = getEnvInt("MAX_QUANTITY", 100)
+	startedAt        time.Time // set at the top of main
+
+	// Deployment label (dev/staging/prod) for /metrics and the exporters
+	environment = getEnvString("ENVIRONMENT", "")
+
+	breakerMinRequests = getBreakerMinRequests()
+
+	// Probes allowed through when half-open. gobreaker uses the same number
+	// as the success threshold: the circuit closes after this many
+	// consecutive successful probes, any failed probe reopens it, and
+	// requests beyond it are rejected with ErrTooManyRequests meanwhile.
+	breakerMaxRequests = getBreakerMaxRequests()
+)
+
+const (
+	// Trip rules applied by readyToTrip
+	tripConsecutiveFailures = 3
+	tripFailureRatio        = 0.5
+	// Window after which closed-state counts reset
+	breakerInterval = 20 * time.Second
+	// How long the circuit stays open before allowing half-open probes
+	breakerTimeout = 10 * time.Second
+)
+
+// Non-standard status (as used by nginx) for requests abandoned by the client
+const statusClientClosedRequest = 499
+
+// Percentile methods selectable via PERCENTILE_METHOD
+const (
+	percentileNearestRank = "nearest-rank"
+	percentileLinear      = "linear"
 )
 
 func main() {
+	startedAt = time.Now()
+
 	// Configure Circuit Breaker with more sensitive settings
-	cb = gobreaker.NewCircuitBreaker(gobreaker.Settings{
-		Name:        "payment-service",
-		MaxRequests: 2,                // Fewer requests in half-open state
-		Interval:    20 * time.Second, // Shorter tracking window
-		Timeout:     10 * time.Second, // Faster recovery attempts
-		ReadyToTrip: func(counts gobreaker.Counts) bool {
-			// Trip on either 3 consecutive failures OR 50% failure rate
-			if counts.ConsecutiveFailures >= 3 {
-				return true
-			}
-			if counts.Requests >= 5 {
-				failureRatio := float64(counts.TotalFailures) / float64(counts.Requests)
-				return failureRatio >= 0.5
+	settings := breakerSettings("payment-service")
+	settings.ReadyToTrip = breakerTripPolicy
+	// The sliding window replaces the interval reset; closed-state counts
+	// then only feed the consecutive-failure rule
+	settings.Interval = closedInterval()
+	settings.OnStateChange = func(name string, from gobreaker.State, to gobreaker.State) {
+		log.Printf("🔌 STATE CHANGE: %s → %s", from, to)
+		eventLog.recordStateChange(from, to)
+		breakerGeneration.stateChanged(to)
+		notifyStateChange(name, from, to)
+		statsd.emitStateChange(to)
+		if to == gobreaker.StateHalfOpen {
+			log.Println("⚠️ Attempting recovery in half-open state")
+		}
+		if from == gobreaker.StateHalfOpen && to == gobreaker.StateClosed {
+			slowStart.begin()
+		}
+		if to == gobreaker.StateOpen {
+			recordTrip()
+			if slidingWindow != nil {
+				slidingWindow.reset(time.Now())
 			}
-			return false
-		},
-		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
-			log.Printf("🔌 STATE CHANGE: %s → %s", from, to)
-			if to == gobreaker.StateHalfOpen {
-				log.Println("⚠️ Attempting recovery in half-open state")
+			markCircuitOpened()
+			if sharedState != nil {
+				// Don't call Redis while the breaker holds its lock
+				go sharedState.trip()
 			}
-		},
-	})
+		}
+		if to == gobreaker.StateOpen && resetMetricsOnTrip {
+			archiveIncident()
+		}
+	}
+	cb = gobreaker.NewCircuitBreaker(settings)
 
 	// Serve static frontend
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "./static/index.html")
-	})
+	handleRoute("/", []string{http.MethodGet}, "Demo frontend (index.html for any unmatched path)", handleStatic)
+	handleRoute("/dashboard", []string{http.MethodGet}, "Live latency, error rate and circuit state charts", handleDashboard)
 
 	// Checkout endpoint
-	http.HandleFunc("/api/checkout", handleCheckout)
+	handleRoute("/api/checkout", []string{http.MethodPost}, "Place one checkout through the breaker", handleCheckout)
+	handleRoute("/api/checkout/batch", []string{http.MethodPost}, "Place several checkouts in one request", handleCheckoutBatch)
+	handleRoute("/api/orders/", []string{http.MethodGet}, "Poll an async order by ID (QUEUE_ASYNC)", handleOrder)
 
 	// Enhanced metrics endpoint
-	http.HandleFunc("/metrics", handleMetrics)
+	handleRoute("/metrics", []string{http.MethodGet}, "Request, latency and breaker metrics", handleMetrics)
+	handleRoute("/metrics/incidents", []string{http.MethodGet}, "Metrics archived at each circuit trip", handleIncidents)
+	handleRoute("/metrics/influx", []string{http.MethodGet}, "Metrics in InfluxDB line protocol", handleInfluxMetrics)
+	handleRoute("/metrics/baseline", []string{http.MethodPost}, "Capture a baseline for /metrics/delta", handleMetricsBaseline)
+	handleRoute("/metrics/reset", []string{http.MethodPost}, "Reset metrics (?scope=all|latency)", handleMetricsReset)
+	handleRoute("/metrics/delta", []string{http.MethodGet}, "Metrics change since the baseline", handleMetricsDelta)
+	handleRoute("/metrics/timeseries", []string{http.MethodGet}, "Recent periodic metric snapshots (?since=, ?limit=)", handleMetricsTimeseries)
+	registerHistogramImage()
+	handleRoute("/scale-signal", []string{http.MethodGet}, "Windowed p99 latency in ms for autoscalers", handleScaleSignal)
 
 	// Circuit breaker state endpoint with counts
-	http.HandleFunc("/circuit-state", func(w http.ResponseWriter, r *http.Request) {
-		currentCounts := cb.Counts()
-		stateInfo := struct {
-			State  gobreaker.State
-			Counts gobreaker.Counts
-		}{
-			State:  cb.State(),
-			Counts: currentCounts,
-		}
-		json.NewEncoder(w).Encode(stateInfo)
-	})
+	handleRoute("/circuit-state", []string{http.MethodGet}, "Breaker state and counts (?tenant=, ?name=webhook)", handleCircuitState)
+	handleRoute("/circuit-config", []string{http.MethodGet}, "Effective breaker settings", handleCircuitConfig)
+	handleRoute("/circuit-state/force", []string{http.MethodGet, http.MethodPost}, "Manual breaker override (admin)", handleForceState)
+
+	// Readiness endpoint backed by the background downstream probe
+	startHealthChecker(flakyServiceURL, getEnvDuration("HEALTH_CHECK_INTERVAL", 5*time.Second))
+
+	// State-change notifications, behind their own breaker
+	startWebhookNotifier()
+
+	// Periodic metrics summary for runs without the dashboard
+	startMetricsLogger(getEnvDuration("METRICS_LOG_INTERVAL", 0))
+
+	// Snapshot history behind /metrics/timeseries and the dashboard
+	timeseries.start()
+
+	// Background sync of the Redis-shared breaker state
+	sharedState.start()
+
+	// AIMD tuning of the bulkhead limit
+	if bulkhead != nil {
+		bulkhead.startAdaptive()
+	}
+	handleRoute("/ready", []string{http.MethodGet}, "Readiness from the downstream probe, warm-up and recent success rate", handleReady)
+
+	// Checkout over gRPC, sharing the breaker and metrics
+	startGRPCServer()
+
+	// Runtime feature flags
+	handleRoute("/admin/flags", []string{http.MethodGet, http.MethodPost}, "List or update runtime feature flags (admin)", handleAdminFlags)
+
+	// Breaker event log for post-incident analysis
+	handleRoute("/debug/events", []string{http.MethodGet}, "Breaker event log (?replay=true)", handleDebugEvents)
+	handleRoute("/debug/requests", []string{http.MethodGet}, "Sampled request/response pairs", handleDebugRequests)
+	handleRoute("/debug/endpoints", []string{http.MethodGet}, "This list of endpoints", handleDebugEndpoints)
 
 	// System health endpoint
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("🟢 System Operational"))
+	handleRoute("/health", []string{http.MethodGet}, "Liveness check", func(w http.ResponseWriter, r *http.Request) {
+		if !breakerEnabled {
+			writeText(w, http.StatusOK, "🟢 System Operational (No Protection)")
+			return
+		}
+		writeText(w, http.StatusOK, "🟢 System Operational")
 	})
 
-	log.Println("🚀 Store API running on :8080 WITH ENHANCED CIRCUIT BREAKER")
+	log.Printf("🔌 Ratio trip needs at least %d requests per interval", breakerMinRequests)
+	log.Printf("🔌 Half-open allows %d probes; as many consecutive successes close the circuit", breakerMaxRequests)
+	log.Printf("📊 Percentile method: %s", percentileMethod)
+	if breakerWarmup > 0 {
+		log.Printf("🌡️ /ready reports not-ready for a %s warm-up", breakerWarmup)
+	}
+	var handler http.Handler = withRequestID(withRecovery(routes.mux))
+
+	// Optional cleartext HTTP/2 for local benchmarking; HTTP/1.1 stays the default
+	if getEnvBool("ENABLE_H2C", false) {
+		handler = withH2C(handler)
+		log.Println("⚡ h2c enabled: serving HTTP/2 without TLS")
+	}
+
+	listener, err := net.Listen("tcp", ":8080")
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Optional TCP-level cap: connections past the limit wait in the accept
+	// backlog instead of all being served at once
+	if maxConns := getEnvInt("MAX_CONNECTIONS", 0); maxConns > 0 {
+		listener = netutil.LimitListener(listener, maxConns)
+		log.Printf("🚧 Limiting to %d concurrent connections", maxConns)
+	}
+
+	logEffectiveConfig()
+	if breakerEnabled {
+		log.Println("🚀 Store API running on :8080 WITH ENHANCED CIRCUIT BREAKER")
+	} else {
+		log.Println("🚀 Store API running on :8080 WITHOUT CIRCUIT BREAKER (ENABLE_BREAKER=false)")
+		log.Println("⚠️  WARNING: No failure protection - timeouts will block!")
+	}
 	log.Println("📍 Open http://localhost:8080 in your browser")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	serveUntilSignal(listener, handler)
+}
+
+// Base settings shared by every payment breaker
+func breakerSettings(name string) gobreaker.Settings {
+	return gobreaker.Settings{
+		Name:         name,
+		MaxRequests:  breakerMaxRequests, // Fewer requests in half-open state
+		Interval:     breakerInterval,    // Shorter tracking window
+		Timeout:      breakerTimeout,     // Faster recovery attempts
+		ReadyToTrip:  readyToTrip,
+		IsSuccessful: isBreakerSuccess,
+	}
+}
+
+// Trip on either 3 consecutive failures OR 50% failure rate, the latter only
+// once CB_MIN_REQUESTS requests have been seen in the interval
+func readyToTrip(counts gobreaker.Counts) bool {
+	if counts.ConsecutiveFailures >= tripConsecutiveFailures {
+		return true
+	}
+	if counts.Requests >= uint32(breakerMinRequests) {
+		failureRatio := float64(counts.TotalFailures) / float64(counts.Requests)
+		return failureRatio >= tripFailureRatio
+	}
+	return false
+}
+
+// Get the ratio-trip warm-up size with default (at least 1)
+func getBreakerMinRequests() int {
+	n := getEnvInt("CB_MIN_REQUESTS", 5)
+	if n < 1 {
+		log.Printf("⚠️ CB_MIN_REQUESTS must be at least 1, using 1")
+		return 1
+	}
+	return n
+}
+
+// Get the half-open probe count with default (at least 1)
+func getBreakerMaxRequests() uint32 {
+	n := getEnvInt("CB_HALF_OPEN_MAX_REQUESTS", 2)
+	if n < 1 {
+		log.Printf("⚠️ CB_HALF_OPEN_MAX_REQUESTS must be at least 1, using 1")
+		return 1
+	}
+	return uint32(n)
 }
 
 func handleCheckout(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 
+	if !acceptBody(w, r) {
+		return
+	}
+
+	// Buffer the body so the raw payload is still available for debug logging
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		rejectBody(w, err)
+		return
+	}
+
 	var req CheckoutRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Invalid request format"))
+	if err := json.Unmarshal(body, &req); err != nil {
+		logRawBody("Invalid request format", body)
+		requestSamples.maybeRecord(r, body, http.StatusBadRequest, "Invalid request format", time.Since(start))
+		writeText(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	// A repeated Idempotency-Key gets the original result, not a second charge
+	key := idempotencyKey(r)
+	if key != "" {
+		stored, ok := idempotencyStore.get(key)
+		if ok {
+			log.Printf("🔁 IDEMPOTENT REPLAY [%s]", requestID(r))
+			w.Header().Set("Idempotent-Replayed", "true")
+			writeJSON(w, stored.Status, stored.Body)
+			return
+		}
+	}
+
+	// Rather than block behind a busy pool, hand the checkout off and let
+	// the client poll for the result
+	if queueAsync && checkoutPool != nil && checkoutPool.mustWait() {
+		result := submitAsync(r, req, start, func(result CheckoutResult) {
+			logSlowCheckout(r, req, result.Status, time.Since(start))
+			if key != "" {
+				idempotencyStore.put(key, result)
+			}
+		})
+		writeJSON(w, result.Status, result.Body)
 		return
 	}
 
-	flakyURL := getFlakyServiceURL()
+	result := runCheckout(r, req, start)
+	logSlowCheckout(r, req, result.Status, time.Since(start))
+	if key != "" {
+		idempotencyStore.put(key, result)
+	}
+	if deadline, ok := requestDeadline(r, start); ok && result.Status == http.StatusOK {
+		setTimeRemaining(w, deadline)
+	}
+	if result.Status == http.StatusBadGateway {
+		logRawBody("Checkout failed", body)
+	}
+	requestSamples.maybeRecord(r, body, result.Status, result.Body, time.Since(start))
+	writeJSON(w, result.Status, result.Body)
+}
 
-	// Execute via circuit breaker
-	result, err := cb.Execute(func() (interface{}, error) {
-		return callPaymentService(flakyURL)
-	})
+// Outcome of a single checkout: the HTTP status and the JSON body to send
+type CheckoutResult struct {
+	Status int
+	Body   interface{}
+}
+
+// Run one decoded checkout through validation, the breaker and the payment
+// service. Shared by the single and batch checkout endpoints.
+func processCheckout(r *http.Request, req CheckoutRequest, start time.Time) CheckoutResult {
+	// Each tenant gets its own breaker so one tenant can't trip the others
+	breaker, tenantErr := breakerForRequest(r)
+	if tenantErr != nil {
+		return CheckoutResult{tenantErr.status, map[string]string{
+			"error": tenantErr.Error(),
+		}}
+	}
+
+	// Charge for every unit; quantity must be 1..MAX_QUANTITY
+	quantity := req.quantity()
+	if quantity < 1 || quantity > maxQuantity {
+		return CheckoutResult{http.StatusUnprocessableEntity, map[string]string{
+			"error": fmt.Sprintf("Quantity must be between 1 and %d", maxQuantity),
+		}}
+	}
+	total := req.Price * float64(quantity)
+
+	if req.Currency != "" && !supportedCurrency(req.Currency) {
+		return CheckoutResult{http.StatusUnprocessableEntity, map[string]string{
+			"error": fmt.Sprintf("unsupported currency %q", req.Currency),
+		}}
+	}
+
+	// Convert to the base currency before charging
+	charged := total
+	if exchange != nil {
+		converted, err := exchange.toBase(total, req.Currency)
+		if err != nil {
+			return CheckoutResult{http.StatusUnprocessableEntity, map[string]string{
+				"error": err.Error(),
+			}}
+		}
+		charged = converted
+	}
+
+	// Reject tampered prices before any downstream work
+	if catalog != nil {
+		if rejected := catalog.validate(req.Item, charged/float64(quantity)); rejected != nil {
+			return *rejected
+		}
+	}
+
+	// Simulated api-side work (e.g. tax calculation); give up early if the
+	// client has already gone away
+	if processingDelay > 0 {
+		timer := time.NewTimer(processingDelay)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			log.Printf("🚪 Client disconnected during processing delay [%s]", requestID(r))
+			return CheckoutResult{statusClientClosedRequest, map[string]string{
+				"error": "Client closed request",
+			}}
+		}
+	}
+
+	// Dry runs stop here: no breaker, no downstream, counted separately
+	if isDryRun(r) {
+		duration := time.Since(start)
+		recordDryRun()
+		log.Printf("🧪 DRY RUN: %s for $%.2f (%s)", req.Item, charged, duration)
+		return CheckoutResult{http.StatusOK, map[string]string{
+			"status":  "dry_run_ok",
+			"item":    req.Item,
+			"charged": chargedString(req, charged),
+			"latency": duration.String(),
+		}}
+	}
+
+	// Degraded mode: the health probe says the downstream is down, so answer
+	// with the fallback straight away instead of spending requests on it
+	if featureFlags.enabled(flagDegradedMode) && downstreamHealth.knownDown() {
+		recordDegraded()
+		log.Printf("🩹 DEGRADED MODE: fallback without calling downstream (%s)", time.Since(start))
+		return fallbackResult("downstream unhealthy", time.Since(start))
+	}
+
+	// Under stress, normal-priority traffic goes first
+	if shedLowPriority(r) {
+		log.Printf("🎟️ SHED: normal-priority checkout deferred (%s)", time.Since(start))
+		return CheckoutResult{http.StatusServiceUnavailable, map[string]string{
+			"error":   "Shedding low-priority traffic",
+			"advice":  "Try again shortly",
+			"latency": time.Since(start).String(),
+		}}
+	}
+
+	// Just-recovered downstream: hold back traffic until the ramp allows it
+	if !bypassesShedding(r) && !slowStart.allow() {
+		log.Printf("🐢 SLOW START: Request held back (%s)", time.Since(start))
+		return CheckoutResult{http.StatusServiceUnavailable, map[string]string{
+			"error":   "Service recovering",
+			"advice":  "Try again shortly",
+			"state":   "slow-start",
+			"latency": time.Since(start).String(),
+		}}
+	}
+
+	// Carry the request ID downstream, but don't let a client disconnect
+	// cancel the payment call and register as a downstream failure
+	downstreamCtx := context.WithoutCancel(r.Context())
+
+	// Honour the client's X-Timeout-Ms budget, and don't start a downstream
+	// call that can't finish inside it
+	if deadline, ok := requestDeadline(r, start); ok {
+		if time.Until(deadline) < minCallBudget {
+			log.Printf("⏳ BUDGET EXHAUSTED: %s left before the downstream call [%s]", time.Until(deadline).Round(time.Millisecond), requestID(r))
+			return CheckoutResult{http.StatusServiceUnavailable, map[string]string{
+				"error":   "Insufficient time budget",
+				"advice":  "Retry with a larger X-Timeout-Ms",
+				"latency": time.Since(start).String(),
+			}}
+		}
+		var cancel context.CancelFunc
+		downstreamCtx, cancel = context.WithDeadline(downstreamCtx, deadline)
+		defer cancel()
+	}
+
+	// Bound concurrent downstream calls; the slot is freed once the call is done
+	var callErr error
+	var callLatency time.Duration
+	if bulkhead != nil {
+		if !bulkhead.acquire() {
+			log.Printf("🚧 BULKHEAD FULL: Request rejected (%s)", time.Since(start))
+			return CheckoutResult{http.StatusServiceUnavailable, map[string]string{
+				"error":   "Too many concurrent requests",
+				"advice":  "Try again shortly",
+				"latency": time.Since(start).String(),
+			}}
+		}
+		defer func() { bulkhead.release(callErr, callLatency) }()
+	}
+
+	// Execute via circuit breaker (or directly, for the canary cohort)
+	callStart := time.Now()
+	var retries int
+	pay := func() (interface{}, error) {
+		resp, attemptsRetried, err := callPaymentService(downstreamCtx, flakyServiceURL, charged, retriesAllowed(r))
+		retries = attemptsRetried
+		return resp, err
+	}
+	route := chooseRoute()
+	var result interface{}
+	var err error
+	coalesced := false
+	if key := coalesceKey(r, req.Item, charged); route == routeBreaker && coalescer != nil && key != "" {
+		result, coalesced, err = coalescer.do(key, func() (interface{}, error) {
+			return executePayment(breaker, pay)
+		})
+		if coalesced {
+			recordCoalesced()
+			log.Printf("🤝 COALESCED: %s for $%.2f shared an in-flight payment call", req.Item, charged)
+		}
+	} else if route == routeBreaker {
+		result, err = executePayment(breaker, pay)
+	} else {
+		result, err = pay()
+	}
+	callErr, callLatency = err, time.Since(callStart)
+
+	// The breaker has already counted a degraded reply as a failure, but the
+	// payment itself went through
+	breakerErr := err
+	if isDegraded(err) {
+		log.Printf("📈 DEGRADED: %v", err)
+		err = nil
+	}
 
 	duration := time.Since(start)
-	updateMetrics(err, duration, cb.State())
+	state := breaker.State()
+	if err == gobreaker.ErrOpenState {
+		// May have been rejected by the shared circuit while the local one is closed
+		state = gobreaker.StateOpen
+	}
+	if breaker == cb {
+		breakerGeneration.observe(state)
+	}
+	outcome := RequestOutcome{Err: err, Latency: duration, State: state, Retries: retries, Route: route, Priority: requestPriority(r)}
+	updateMetrics(outcome)
+	statsd.emitOutcome(outcome)
+	if route == routeBreaker && breaker == cb && !coalesced && err != gobreaker.ErrOpenState && err != gobreaker.ErrTooManyRequests {
+		eventLog.recordOutcome(breakerErr)
+		if slidingWindow != nil {
+			slidingWindow.record(start, time.Now(), isBreakerSuccess(breakerErr))
+		}
+	}
+	logSampledCheckout(r, req, result, err, duration, state)
 
 	// Handle circuit breaker rejection
 	if err == gobreaker.ErrOpenState {
 		log.Printf("⚡ FAST FAIL: Request rejected (%.0fms) - Circuit OPEN", duration.Seconds()*1000)
-		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(map[string]string{
+		if breakerFailMode == failModeOpen {
+			recordFailOpen()
+			return failOpenResult(req.Item, charged, duration)
+		}
+		if featureFlags.enabled(flagEnableFallback) {
+			return fallbackResult("circuit open", duration)
+		}
+		if openResponseTemplate != "" {
+			rendered := renderOpenResponse(openResponseTemplate, "open", duration.String(), retryAfterSeconds())
+			return CheckoutResult{http.StatusServiceUnavailable, json.RawMessage(rendered)}
+		}
+		return CheckoutResult{http.StatusServiceUnavailable, map[string]string{
 			"error":   "Service unavailable",
 			"advice":  "Try again shortly",
 			"state":   "open",
 			"latency": duration.String(),
-		})
-		return
+		}}
+	}
+
+	// Handle business-rule rejections from the payment service
+	if isDeclined(err) {
+		log.Printf("🚫 DECLINED: %s for $%.2f (%.0fms)", req.Item, charged, duration.Seconds()*1000)
+		return CheckoutResult{http.StatusPaymentRequired, map[string]string{
+			"error":   "Payment declined",
+			"reason":  "Amount exceeds the payment processor's limit",
+			"latency": duration.String(),
+		}}
 	}
 
 	// Handle service failures
 	if err != nil {
 		log.Printf("❌ FAILURE: %v (%.0fms)", err, duration.Seconds()*1000)
-		w.WriteHeader(http.StatusBadGateway)
-		json.NewEncoder(w).Encode(map[string]interface{}{
+		if featureFlags.enabled(flagEnableFallback) {
+			return fallbackResult(err.Error(), duration)
+		}
+		return CheckoutResult{http.StatusBadGateway, map[string]interface{}{
 			"error":      "Payment processing failed",
 			"root_cause": err.Error(),
 			"latency":    duration.String(),
-		})
-		return
+		}}
 	}
 
 	// Success case
-	resp := result.(*http.Response)
+	resp, ok := result.(*http.Response)
+	if !ok {
+		log.Printf("💥 INTERNAL: unexpected payment result type %T", result)
+		return CheckoutResult{http.StatusInternalServerError, map[string]string{
+			"error": "Internal error: unexpected payment service result",
+		}}
+	}
 	defer resp.Body.Close()
 
-	log.Printf("✅ SUCCESS: %s for $%.2f (%s)", req.Item, req.Price, duration)
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "confirmed",
-		"item":    req.Item,
-		"charged": fmt.Sprintf("%.2f", req.Price),
-		"latency": duration.String(),
-	})
+	log.Printf("✅ SUCCESS: %s for $%.2f (%s)", req.Item, charged, duration)
+	response := map[string]interface{}{
+		"status":     "confirmed",
+		"item":       req.Item,
+		"unit_price": fmt.Sprintf("%.2f", req.Price),
+		"quantity":   quantity,
+		"charged":    chargedString(req, charged),
+		"latency":    duration.String(),
+		"retries":    retries,
+		"retried":    retries > 0,
+	}
+	if breakerTrafficPct < 100 {
+		response["route"] = route
+	}
+	addResponseFields(response, r, state)
+	if exchange != nil {
+		response["base_currency"] = baseCurrency
+		response["original_amount"] = fmt.Sprintf("%.2f", total)
+		response["original_currency"] = strings.ToUpper(req.Currency)
+		if req.Currency == "" {
+			response["original_currency"] = baseCurrency
+		}
+	}
+	return CheckoutResult{http.StatusOK, response}
+}
+
+// The charged amount as shown to the client: plain "%.2f" unless the request
+// named a currency, then in that currency's format. With conversion enabled
+// the amount is in the base currency, so that is the format used.
+func chargedString(req CheckoutRequest, charged float64) string {
+	if req.Currency == "" {
+		return fmt.Sprintf("%.2f", charged)
+	}
+	if exchange != nil {
+		return formatMoney(charged, baseCurrency)
+	}
+	return formatMoney(charged, req.Currency)
+}
+
+// A checkout is a dry run with ?dry_run=true or an X-Dry-Run: true header
+func isDryRun(r *http.Request) bool {
+	if dry, err := strconv.ParseBool(r.URL.Query().Get("dry_run")); err == nil && dry {
+		return true
+	}
+	dry, err := strconv.ParseBool(r.Header.Get("X-Dry-Run"))
+	return err == nil && dry
+}
+
+// Write a JSON response with the given status. The body is encoded before
+// the status goes out, so an unencodable value becomes a clean 500 rather
+// than a truncated response under the intended status. Write errors (usually
+// a client that hung up) are logged with the request ID set by withRequestID.
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	id := w.Header().Get("X-Request-ID")
+	data, err := json.Marshal(body)
+	if err != nil {
+		log.Printf("❌ JSON ENCODE FAILED [%s]: %v", id, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"Internal server error"}` + "\n"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		log.Printf("⚠️ RESPONSE WRITE FAILED [%s]: %v", id, err)
+	}
+}
+
+// Write a plain-text response with the given status
+func writeText(w http.ResponseWriter, status int, text string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(text))
+}
+
+// Circuit breaker state endpoint with counts
+func handleCircuitState(w http.ResponseWriter, r *http.Request) {
+	breaker := cb
+	tenant := r.URL.Query().Get("tenant")
+	name := r.URL.Query().Get("name")
+	switch {
+	case name == "webhook":
+		if webhookCB == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{
+				"error": "Webhook delivery is not configured",
+			})
+			return
+		}
+		breaker = webhookCB
+	case name == priorityHigh && highPriorityCB != nil:
+		breaker = highPriorityCB
+	case name != "":
+		writeJSON(w, http.StatusNotFound, map[string]string{
+			"error": "No breaker named " + name,
+		})
+		return
+	case tenant != "":
+		var ok bool
+		if breaker, ok = tenantBreakers.lookup(tenant); !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{
+				"error": "No breaker for tenant " + tenant,
+			})
+			return
+		}
+	}
+
+	currentState := breaker.State()
+	currentCounts := breaker.Counts()
+	stateInfo := struct {
+		Name                 string `json:"name,omitempty"`
+		Tenant               string `json:"tenant,omitempty"`
+		State                gobreaker.State
+		Counts               gobreaker.Counts
+		Generation           *uint64             `json:"generation,omitempty"`
+		ConsecutiveSuccesses uint32              `json:"consecutive_successes"`
+		ProbesToClose        *uint32             `json:"probes_to_close,omitempty"`
+		ErrorBudget          *ErrorBudgetStats   `json:"error_budget,omitempty"`
+		Override             *OverrideStats      `json:"override,omitempty"`
+		SlidingWindow        *SlidingWindowStats `json:"sliding_window,omitempty"`
+	}{
+		Name:                 name,
+		Tenant:               tenant,
+		State:                currentState,
+		Counts:               currentCounts,
+		ConsecutiveSuccesses: currentCounts.ConsecutiveSuccesses,
+	}
+
+	// The rest describes payment breakers only
+	if breaker == webhookCB {
+		writeJSON(w, http.StatusOK, stateInfo)
+		return
+	}
+
+	// While half-open, show how many more successful probes will close the circuit
+	if currentState == gobreaker.StateHalfOpen {
+		remaining := uint32(0)
+		if currentCounts.ConsecutiveSuccesses < breakerMaxRequests {
+			remaining = breakerMaxRequests - currentCounts.ConsecutiveSuccesses
+		}
+		stateInfo.ProbesToClose = &remaining
+	}
+	// Counts only compare across polls within one generation; tracked for
+	// the main breaker only, which is also the only one that spends the
+	// error budget
+	if breaker == cb {
+		generation := breakerGeneration.observe(currentState)
+		stateInfo.Generation = &generation
+		// A manual override supersedes State until set back to auto
+		if override := breakerOverride.stats(); override.Mode != overrideAuto {
+			stateInfo.Override = &override
+		}
+		if slidingWindow != nil {
+			window := slidingWindow.stats(time.Now())
+			stateInfo.SlidingWindow = &window
+		}
+		if errorBudget != nil {
+			budget := errorBudget.stats()
+			stateInfo.ErrorBudget = &budget
+		}
+	}
+	writeJSON(w, http.StatusOK, stateInfo)
+}
+
+// Active breaker settings, as resolved from the environment. Read-only and
+// lock-free: everything here is fixed once the breaker is built.
+func handleCircuitConfig(w http.ResponseWriter, r *http.Request) {
+	config := struct {
+		Name                    string  `json:"name"`
+		HalfOpenMaxRequests     uint32  `json:"half_open_max_requests"`
+		Interval                string  `json:"interval"`
+		Timeout                 string  `json:"timeout"`
+		TimeoutJitter           string  `json:"timeout_jitter"`
+		TripConsecutiveFailures uint32  `json:"trip_consecutive_failures"`
+		TripFailureRatio        float64 `json:"trip_failure_ratio"`
+		MinRequests             int     `json:"min_requests"`
+		// When set, the error budget replaces the two trip rules above
+		ErrorBudget *ErrorBudgetStats `json:"error_budget,omitempty"`
+	}{
+		Name:                    cb.Name(),
+		HalfOpenMaxRequests:     breakerMaxRequests,
+		Interval:                closedInterval().String(),
+		Timeout:                 breakerTimeout.String(),
+		TimeoutJitter:           breakerTimeoutJitter.String(),
+		TripConsecutiveFailures: tripConsecutiveFailures,
+		TripFailureRatio:        tripFailureRatio,
+		MinRequests:             breakerMinRequests,
+	}
+	if errorBudget != nil {
+		budget := errorBudget.stats()
+		config.ErrorBudget = &budget
+	}
+	writeJSON(w, http.StatusOK, config)
 }
 
-// Helper function for service calls
-func callPaymentService(baseURL string) (*http.Response, error) {
-	client := &http.Client{Timeout: 3 * time.Second}
-	resp, err := client.Get(baseURL + "/process")
+// Helper function for service calls; failed attempts are retried up to
+// MAX_RETRIES times while the shared retry budget allows, and only when
+// retryable (see retriesAllowed). A non-retryable failure is returned as
+// is. Also returns the number of retries made.
+func callPaymentService(ctx context.Context, baseURL string, amount float64, retryable bool) (*http.Response, int, error) {
+	retryBudget.deposit()
+
+	retries := 0
+	resp, err := doPaymentRequest(ctx, baseURL, amount)
+	if err != nil && !isDeclined(err) && !retryable && maxRetries > 0 && featureFlags.enabled(flagEnableRetries) {
+		log.Printf("🔂 Not retrying a non-idempotent checkout: %v", err)
+	}
+	for err != nil && !isDeclined(err) && retryable && retries < maxRetries && featureFlags.enabled(flagEnableRetries) {
+		if !retryBudget.withdraw() {
+			log.Printf("🪙 Retry budget exhausted, not retrying: %v", err)
+			break
+		}
+		// Linear backoff, cut short if the caller gives up or the deadline passes
+		backoff := time.NewTimer(retryBackoff * time.Duration(retries+1))
+		select {
+		case <-backoff.C:
+		case <-ctx.Done():
+			backoff.Stop()
+			log.Printf("🚪 Gave up during retry backoff: %v", err)
+			return resp, retries, err
+		}
+		retries++
+		resp, err = doPaymentRequest(ctx, baseURL, amount)
+	}
+	if err == nil {
+		// A 200 from an overloaded downstream still counts against the breaker
+		err = checkLoadSignal(resp)
+	}
+	return resp, retries, err
+}
+
+// Single attempt against the payment service, forwarding the amount so it
+// can enforce its own limits and the request ID for log correlation
+func doPaymentRequest(ctx context.Context, baseURL string, amount float64) (*http.Response, error) {
+	target := baseURL + "/process?amount=" + strconv.FormatFloat(amount, 'f', 2, 64)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if id, ok := ctx.Value(requestIDKey).(string); ok {
+		req.Header.Set("X-Request-ID", id)
+	}
+
+	resp, err := paymentClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("service error (%d: %s)", resp.StatusCode, resp.Status)
+		// Drain and close so the connection goes back to the pool instead of leaking
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return nil, &DownstreamStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	if err := checkSoftFailure(resp); err != nil {
+		return nil, err
 	}
 	return resp, nil
 }
 
+// Largest 200 body inspected for a business-level failure flag
+const maxPaymentBodyBytes = 64 << 10
+
+// A 200 whose JSON body reports {"success": false}; counts as a failure
+// for the client and the breaker despite the status
+type SoftFailureError struct {
+	Reason string
+}
+
+func (e *SoftFailureError) Error() string {
+	return fmt.Sprintf("payment failed despite 200: %s", e.Reason)
+}
+
+// Read the 200 body and fail on "success": false. Non-JSON bodies and ones
+// without the flag pass; the body is put back for later readers.
+func checkSoftFailure(resp *http.Response) error {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPaymentBodyBytes))
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var verdict struct {
+		Success *bool  `json:"success"`
+		Reason  string `json:"reason"`
+	}
+	if json.Unmarshal(body, &verdict) != nil || verdict.Success == nil || *verdict.Success {
+		return nil
+	}
+	reason := verdict.Reason
+	if reason == "" {
+		reason = "unspecified"
+	}
+	return &SoftFailureError{Reason: reason}
+}
+
+// Non-200 reply from the payment service
+type DownstreamStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *DownstreamStatusError) Error() string {
+	return fmt.Sprintf("service error (%d: %s)", e.StatusCode, e.Status)
+}
+
+// A 402 is the payment service applying a business rule, not an outage
+func isDeclined(err error) bool {
+	var statusErr *DownstreamStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusPaymentRequired
+}
+
+// Failure classes reported separately in /metrics
+const (
+	failureConnection = "connection"
+	failureTimeout    = "timeout"
+	failureHTTP5xx    = "http_5xx"
+)
+
+// Tell apart a downstream that is down, slow or erroring. A dial that
+// times out (HTTP_DIAL_TIMEOUT) is a connection failure; other timeouts are
+// checked next because a client timeout also surfaces as a net.Error.
+// Anything else (breaker rejections, other statuses) has no class.
+func classifyFailure(err error) string {
+	var netErr net.Error
+	var opErr *net.OpError
+	var statusErr *DownstreamStatusError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return failureConnection
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return failureTimeout
+	case errors.As(err, &statusErr):
+		if statusErr.StatusCode >= 500 {
+			return failureHTTP5xx
+		}
+		return ""
+	case errors.As(err, &netErr):
+		return failureConnection
+	default:
+		return ""
+	}
+}
+
+// Declines reach the breaker as errors but must not count toward tripping it
+func isBreakerSuccess(err error) bool {
+	return err == nil || isDeclined(err)
+}
+
+// One finished checkout as recorded by updateMetrics
+type RequestOutcome struct {
+	Err      error
+	Latency  time.Duration
+	State    gobreaker.State
+	Retries  int
+	Route    string
+	Priority string
+}
+
 // Centralized metrics update with thread safety
-func updateMetrics(err error, latency time.Duration, state gobreaker.State) {
+func updateMetrics(outcome RequestOutcome) {
+	err, latency := outcome.Err, outcome.Latency
+
 	metrics.mu.Lock()
 	defer metrics.mu.Unlock()
 
+	// time.Since is monotonic, but a zero or negative duration here means
+	// the start time came from somewhere else; clamp it so one bad sample
+	// can't drag the sums and percentiles below zero
+	if latency <= 0 {
+		log.Printf("⏱️ Non-positive latency %s recorded as 0", latency)
+		metrics.SkewedSamples++
+		latency = 0
+	}
+
 	metrics.TotalRequests++
+	metrics.TotalRetries += outcome.Retries
 	metrics.TotalLatency += latency
-	metrics.LatencyHistory = append(metrics.LatencyHistory, latency) // ← Add this
+	metrics.Latencies.add(latency)
+	metrics.Recent.add(time.Now(), latency, err == nil)
+	category := outcomeCategory(err)
+	if metrics.OutcomeLatency[category] == nil {
+		metrics.OutcomeLatency[category] = newLatencySamples()
+	}
+	metrics.OutcomeLatency[category].add(latency)
 
-	if err != nil {
+	// Only requests that actually reached the downstream count toward its latency
+	if err != gobreaker.ErrOpenState && err != gobreaker.ErrTooManyRequests {
+		metrics.DownstreamRequests++
+		metrics.DownstreamLatency += latency
+	}
+
+	recordCohort(metrics.Cohorts, outcome.Route, err, latency)
+	if priorityEnabled {
+		recordCohort(metrics.Priorities, outcome.Priority, err, latency)
+	}
+
+	if isDeclined(err) {
+		metrics.Declined++
+	} else if err != nil {
 		metrics.FailedRequests++
-		if state == gobreaker.StateOpen {
+		// Count actual rejections, not failures that happened to trip the circuit
+		if err == gobreaker.ErrOpenState {
 			metrics.CircuitOpenRejects++
 		}
+		switch classifyFailure(err) {
+		case failureConnection:
+			metrics.ConnectionErrors++
+		case failureTimeout:
+			metrics.TimeoutErrors++
+		case failureHTTP5xx:
+			metrics.HTTP5xxErrors++
+		}
 	} else {
 		metrics.SuccessfulRequests++
 	}
 }
 
-// Get flaky service URL with default
+// Outcome categories for the per-outcome percentile breakdown
+const (
+	outcomeSuccess           = "success"
+	outcomeDeclined          = "declined"
+	outcomeFastFail          = "fast_fail"
+	outcomeDownstreamFailure = "downstream_failure"
+)
+
+func outcomeCategory(err error) string {
+	switch {
+	case err == nil:
+		return outcomeSuccess
+	case isDeclined(err):
+		return outcomeDeclined
+	case err == gobreaker.ErrOpenState || err == gobreaker.ErrTooManyRequests:
+		return outcomeFastFail
+	default:
+		return outcomeDownstreamFailure
+	}
+}
+
+func recordTrip() {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	metrics.CircuitTrips++
+}
+
+func recordDryRun() {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	metrics.DryRuns++
+}
+
+func recordFailOpen() {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	metrics.FailOpenResponses++
+}
+
+func recordCoalesced() {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	metrics.Coalesced++
+}
+
+func recordDegraded() {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	metrics.DegradedFallbacks++
+}
+
+func recordPanic() {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	metrics.Panics++
+}
+
+// Get flaky service URL with default; a malformed value is fatal at startup
+// rather than a cryptic error on every checkout. Trailing slashes are
+// dropped so baseURL + "/process" never doubles up.
 func getFlakyServiceURL() string {
-	if url := os.Getenv("FLAKY_SERVICE_URL"); url != "" {
-		return url
+	raw := getEnvString("FLAKY_SERVICE_URL", "http://flaky-service:8081")
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		log.Fatalf("❌ Invalid FLAKY_SERVICE_URL %q: %v", raw, err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		log.Fatalf("❌ Invalid FLAKY_SERVICE_URL %q: expected http(s)://host[:port]", raw)
 	}
-	return "http://flaky-service:8081"
+	return strings.TrimRight(raw, "/")
+}
+
+// A percentile requested through PERCENTILES, e.g. {"p99.9", 0.999}
+type PercentileSpec struct {
+	Label    string
+	Fraction float64
+}
+
+// Comma-separated PERCENTILES such as "50,90,99.9" add a percentiles map to
+// /metrics; empty keeps just the named fields
+func getPercentiles() []PercentileSpec {
+	var specs []PercentileSpec
+	for _, field := range strings.Split(getEnvString("PERCENTILES", ""), ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		pct, err := strconv.ParseFloat(field, 64)
+		if err != nil || pct <= 0 || pct > 100 {
+			log.Printf("⚠️ Invalid PERCENTILES entry %q, ignoring", field)
+			continue
+		}
+		specs = append(specs, PercentileSpec{Label: "p" + strconv.FormatFloat(pct, 'f', -1, 64), Fraction: pct / 100})
+	}
+	return specs
+}
+
+// Share of samples (percent) dropped from each end for trimmed_avg_latency
+func getTrimPercent() float64 {
+	pct := getEnvFloat("TRIM_PERCENT", 5)
+	if pct < 0 || pct >= 50 {
+		log.Printf("⚠️ TRIM_PERCENT must be in [0, 50), using 5")
+		return 5
+	}
+	return pct
+}
+
+// Get percentile method with default (nearest-rank keeps the original behavior)
+func getPercentileMethod() string {
+	switch method := getEnvString("PERCENTILE_METHOD", percentileNearestRank); method {
+	case percentileNearestRank:
+		return percentileNearestRank
+	case percentileLinear:
+		return percentileLinear
+	default:
+		log.Printf("⚠️ Unknown PERCENTILE_METHOD %q, using %s", method, percentileNearestRank)
+		return percentileNearestRank
+	}
+}
+
+func systemStatus() string {
+	if !breakerEnabled {
+		return "vulnerable"
+	}
+	return "operational"
+}
+
+// Point-in-time view of the metrics as served by /metrics
+type MetricsSnapshot struct {
+	SystemStatus  string                    `json:"system_status"` // "vulnerable" with ENABLE_BREAKER=false
+	Warning       string                    `json:"warning,omitempty"`
+	Environment   string                    `json:"environment,omitempty"`
+	StartedAt     string                    `json:"started_at"`
+	UptimeSeconds float64                   `json:"uptime_seconds"`
+	CircuitState  gobreaker.State           `json:"circuit_state"`
+	CircuitCounts gobreaker.Counts          `json:"circuit_counts"`
+	TotalRequests int                       `json:"total_requests"`
+	SuccessCount  int                       `json:"success_count"`
+	FailureCount  int                       `json:"failure_count"`
+	DeclinedCount int                       `json:"declined_count"`
+	ConnErrors    int                       `json:"connection_errors"`
+	TimeoutErrors int                       `json:"timeout_errors"`
+	HTTP5xxErrors int                       `json:"http_5xx_errors"`
+	FastFails     int                       `json:"fast_fails"`
+	CircuitTrips  int                       `json:"circuit_trips"`
+	SkewedSamples int                       `json:"skewed_samples"`
+	Panics        int                       `json:"panics"`
+	DryRuns       int                       `json:"dry_runs"`
+	Degraded      int                       `json:"degraded_fallbacks"`
+	Coalesced     int                       `json:"coalesced_requests"`
+	DedupHitRate  float64                   `json:"dedup_hit_rate"` // % of keyed checkouts that skipped the downstream
+	FailOpen      int                       `json:"fail_open_responses"`
+	TotalRetries  int                       `json:"total_retries"`
+	SuccessRate   float64                   `json:"success_rate"`
+	ErrorRate     float64                   `json:"error_rate"`
+	FastFailRate  float64                   `json:"fast_fail_rate"`
+	NoData        bool                      `json:"no_data"`
+	AvgLatency    *string                   `json:"avg_latency"`
+	TrimmedAvg    *string                   `json:"trimmed_avg_latency"` // null in LATENCY_HISTOGRAM mode
+	AvgDownstream *string                   `json:"avg_downstream_latency"`
+	MedianLatency *string                   `json:"median_latency"`
+	P95Latency    *string                   `json:"p95_latency"`
+	P95Recent     *string                   `json:"p95_recent"` // over the last RECENT_WINDOW
+	P99Latency    *string                   `json:"p99_latency"`
+	Percentiles   map[string]string         `json:"percentiles,omitempty"` // from PERCENTILES
+	RetryBudget   RetryBudgetStats          `json:"retry_budget"`
+	SlowStart     SlowStartStats            `json:"slow_start"`
+	Bulkhead      *BulkheadStats            `json:"bulkhead,omitempty"`
+	WorkerPool    *WorkerPoolStats          `json:"worker_pool,omitempty"`
+	Idempotency   IdempotencyStats          `json:"idempotency"`
+	ByOutcome     map[string]OutcomeLatency `json:"percentiles_by_outcome"`
+	Cohorts       map[string]CohortSnapshot `json:"cohorts,omitempty"`
+	Priority      *PriorityStats            `json:"priority,omitempty"`
+
+	// Raw values behind the formatted latency fields, for exporters
+	avgLatency, p50, p95, p99 time.Duration
+}
+
+// Latency percentiles for one outcome category
+type OutcomeLatency struct {
+	Count int    `json:"count"`
+	P50   string `json:"p50"`
+	P95   string `json:"p95"`
+	P99   string `json:"p99"`
 }
 
 func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	response := currentSnapshot()
+	writeJSON(w, http.StatusOK, response)
+}
+
+func currentSnapshot() MetricsSnapshot {
+	// Read the breaker before taking the metrics lock: OnStateChange runs
+	// under the breaker's lock and may itself take the metrics lock
+	currentState := cb.State()
+	currentCounts := cb.Counts()
+
 	metrics.mu.Lock()
 	defer metrics.mu.Unlock()
+	return metrics.snapshotLocked(currentState, currentCounts)
+}
 
+// Build a snapshot of the current metrics; the caller must hold m.mu
+func (m *Metrics) snapshotLocked(state gobreaker.State, counts gobreaker.Counts) MetricsSnapshot {
 	// Calculate metrics
 	avgLatency := time.Duration(0)
 	errorRate := 0.0
 	successRate := 0.0
+	fastFailRate := 0.0
+	dedupHitRate := 0.0
 
-	if metrics.TotalRequests > 0 {
-		avgLatency = metrics.TotalLatency / time.Duration(metrics.TotalRequests)
-		successRate = float64(metrics.SuccessfulRequests) / float64(metrics.TotalRequests) * 100
+	if m.TotalRequests > 0 {
+		avgLatency = m.TotalLatency / time.Duration(m.TotalRequests)
+		successRate = float64(m.SuccessfulRequests) / float64(m.TotalRequests) * 100
 		errorRate = 100 - successRate
+		// Share of all requests shed by the open circuit
+		fastFailRate = float64(m.CircuitOpenRejects) / float64(m.TotalRequests) * 100
 	}
 
-	// Calculate percentiles
-	p50 := calculatePercentile(metrics.LatencyHistory, 0.50)
-	p95 := calculatePercentile(metrics.LatencyHistory, 0.95)
-	p99 := calculatePercentile(metrics.LatencyHistory, 0.99)
+	// Only checkouts with an Idempotency-Key can be deduplicated; a miss that
+	// then coalesced onto an in-flight call still avoided a downstream call
+	idempotency := idempotencyStore.stats()
+	if keyed := idempotency.Hits + idempotency.Misses; keyed > 0 {
+		dedupHitRate = float64(idempotency.Hits+m.Coalesced) / float64(keyed) * 100
+	}
 
-	currentCounts := cb.Counts()
-	currentState := cb.State()
+	// Calculate percentiles, the PERCENTILES extras in the same pass
+	fractions := []float64{0.50, 0.95, 0.99}
+	for _, spec := range extraPercentiles {
+		fractions = append(fractions, spec.Fraction)
+	}
+	values := m.Latencies.percentiles(fractions...)
+	p50, p95, p99 := values[0], values[1], values[2]
 
-	// Create structured metrics response
-	response := struct {
-		SystemStatus  string           `json:"system_status"`
-		CircuitState  gobreaker.State  `json:"circuit_state"`
-		CircuitCounts gobreaker.Counts `json:"circuit_counts"`
-		TotalRequests int              `json:"total_requests"`
-		SuccessCount  int              `json:"success_count"`
-		FailureCount  int              `json:"failure_count"`
-		FastFails     int              `json:"fast_fails"`
-		SuccessRate   float64          `json:"success_rate"`
-		ErrorRate     float64          `json:"error_rate"`
-		AvgLatency    string           `json:"avg_latency"`
-		MedianLatency string           `json:"median_latency"`
-		P95Latency    string           `json:"p95_latency"`
-		P99Latency    string           `json:"p99_latency"`
-	}{
-		SystemStatus:  "operational",
-		CircuitState:  currentState,
-		CircuitCounts: currentCounts,
-		TotalRequests: metrics.TotalRequests,
-		SuccessCount:  metrics.SuccessfulRequests,
-		FailureCount:  metrics.FailedRequests,
-		FastFails:     metrics.CircuitOpenRejects,
+	snapshot := MetricsSnapshot{
+		SystemStatus:  systemStatus(),
+		Environment:   environment,
+		StartedAt:     startedAt.UTC().Format(time.RFC3339),
+		UptimeSeconds: time.Since(startedAt).Seconds(),
+		CircuitState:  state,
+		CircuitCounts: counts,
+		TotalRequests: m.TotalRequests,
+		SuccessCount:  m.SuccessfulRequests,
+		FailureCount:  m.FailedRequests,
+		DeclinedCount: m.Declined,
+		ConnErrors:    m.ConnectionErrors,
+		TimeoutErrors: m.TimeoutErrors,
+		HTTP5xxErrors: m.HTTP5xxErrors,
+		FastFails:     m.CircuitOpenRejects,
+		CircuitTrips:  m.CircuitTrips,
+		SkewedSamples: m.SkewedSamples,
+		Panics:        m.Panics,
+		DryRuns:       m.DryRuns,
+		Degraded:      m.DegradedFallbacks,
+		Coalesced:     m.Coalesced,
+		DedupHitRate:  dedupHitRate,
+		FailOpen:      m.FailOpenResponses,
+		TotalRetries:  m.TotalRetries,
 		SuccessRate:   successRate,
 		ErrorRate:     errorRate,
-		AvgLatency:    avgLatency.String(),
-		MedianLatency: p50.String(),
-		P95Latency:    p95.String(),
-		P99Latency:    p99.String(),
+		FastFailRate:  fastFailRate,
+		RetryBudget:   retryBudget.stats(),
+		SlowStart:     slowStart.stats(),
+		Idempotency:   idempotency,
+		avgLatency:    avgLatency,
+		p50:           p50,
+		p95:           p95,
+		p99:           p99,
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	if !breakerEnabled {
+		snapshot.Warning = fmt.Sprintf("⚠️ All failures wait for full timeout (%s)!", paymentTimeout)
+	}
+
+	// Fast-fails should sit near zero while downstream failures carry the
+	// timeouts; categories with no traffic yet are omitted
+	snapshot.ByOutcome = map[string]OutcomeLatency{}
+	for category, samples := range m.OutcomeLatency {
+		if samples.count() == 0 {
+			continue
+		}
+		values := samples.percentiles(0.50, 0.95, 0.99)
+		snapshot.ByOutcome[category] = OutcomeLatency{
+			Count: samples.count(),
+			P50:   values[0].String(),
+			P95:   values[1].String(),
+			P99:   values[2].String(),
+		}
+	}
+
+	if bulkhead != nil {
+		stats := bulkhead.stats()
+		snapshot.Bulkhead = &stats
+	}
+	if checkoutPool != nil {
+		stats := checkoutPool.stats()
+		snapshot.WorkerPool = &stats
+	}
+
+	// Breaker vs direct comparison, only while traffic is being split
+	if breakerTrafficPct < 100 {
+		snapshot.Cohorts = map[string]CohortSnapshot{}
+		for route, cohort := range m.Cohorts {
+			snapshot.Cohorts[route] = cohort.snapshot()
+		}
+	}
+	if priorityEnabled {
+		snapshot.Priority = &PriorityStats{Shed: priorityShed.Load(), ByPriority: map[string]CohortSnapshot{}}
+		for priority, cohort := range m.Priorities {
+			snapshot.Priority.ByPriority[priority] = cohort.snapshot()
+		}
+	}
+
+	// Latency fields stay null until there is traffic, so "no data" can't be
+	// mistaken for "extremely fast"
+	if m.TotalRequests == 0 {
+		snapshot.NoData = true
+	} else {
+		snapshot.AvgLatency = durationString(avgLatency)
+		if raw := m.Latencies.samples(); len(raw) > 0 {
+			snapshot.TrimmedAvg = durationString(trimmedMean(raw, trimPercent))
+		}
+		if m.DownstreamRequests > 0 {
+			snapshot.AvgDownstream = durationString(m.DownstreamLatency / time.Duration(m.DownstreamRequests))
+		}
+		snapshot.MedianLatency = durationString(p50)
+		snapshot.P95Latency = durationString(p95)
+		snapshot.P99Latency = durationString(p99)
+		if len(extraPercentiles) > 0 {
+			snapshot.Percentiles = map[string]string{}
+			for i, spec := range extraPercentiles {
+				snapshot.Percentiles[spec.Label] = values[3+i].String()
+			}
+		}
+	}
+	if p95Recent, samples := m.recentPercentileLocked(time.Now(), recentWindow, 0.95); samples > 0 {
+		snapshot.P95Recent = durationString(p95Recent)
+	}
+	return snapshot
+}
+
+// Percentile over the last window of recent checkouts and the number of
+// samples behind it. Goes through RecentScratch so histogram mode doesn't
+// sort here either. The caller must hold m.mu.
+func (m *Metrics) recentPercentileLocked(now time.Time, window time.Duration, percentile float64) (time.Duration, int) {
+	latencies := m.Recent.latencies(now, window)
+	m.RecentScratch.fill(latencies)
+	return m.RecentScratch.percentiles(percentile)[0], len(latencies)
+}
+
+// Zero the live counters; the caller must hold m.mu
+func (m *Metrics) resetLocked() {
+	m.TotalRequests = 0
+	m.SuccessfulRequests = 0
+	m.FailedRequests = 0
+	m.CircuitOpenRejects = 0
+	m.Panics = 0
+	m.DryRuns = 0
+	m.DegradedFallbacks = 0
+	m.Coalesced = 0
+	idempotencyStore.resetCounters()
+	m.FailOpenResponses = 0
+	m.Declined = 0
+	m.SkewedSamples = 0
+	m.ConnectionErrors = 0
+	m.TimeoutErrors = 0
+	m.HTTP5xxErrors = 0
+	m.TotalRetries = 0
+	m.TotalLatency = 0
+	m.DownstreamRequests = 0
+	m.DownstreamLatency = 0
+	m.resetLatencyLocked()
+	m.Recent.reset()
+	m.Baseline = nil // deltas across a reset would be meaningless
+	m.Cohorts = map[string]*CohortMetrics{}
+	m.Priorities = map[string]*CohortMetrics{}
+	m.Since = time.Now()
+}
+
+// Clear the samples behind the percentiles, keeping every counter. The
+// latency sums stay too, so avg_latency remains cumulative. The caller
+// must hold m.mu.
+func (m *Metrics) resetLatencyLocked() {
+	m.Latencies.reset()
+	m.OutcomeLatency = map[string]*LatencySamples{}
+	for _, cohort := range m.Cohorts {
+		cohort.Latencies.reset()
+	}
+	for _, cohort := range m.Priorities {
+		cohort.Latencies.reset()
+	}
+}
+
+func durationString(d time.Duration) *string {
+	s := d.String()
+	return &s
 }
 
 func calculatePercentile(latencies []time.Duration, percentile float64) time.Duration {
 	if len(latencies) == 0 {
 		return 0
 	}
+	return percentileOfSorted(sortedLatencies(latencies), percentile)
+}
 
-	// Make a copy to avoid modifying original
-	sorted := make([]time.Duration, len(latencies))
-	copy(sorted, latencies)
-	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i] < sorted[j]
-	})
+// Apply PERCENTILE_METHOD to sorted, non-empty samples
+func percentileOfSorted(sorted []time.Duration, percentile float64) time.Duration {
+	if percentileMethod == percentileLinear {
+		return linearPercentile(sorted, percentile)
+	}
+	return nearestRankPercentile(sorted, percentile)
+}
+
+// Mean of the samples left after dropping trimPct percent from each end of
+// the sorted slice, so a handful of timeouts don't dominate it
+func trimmedMean(latencies []time.Duration, trimPct float64) time.Duration {
+	sorted := sortedLatencies(latencies)
 
-	index := int(float64(len(sorted)) * percentile)
-	if index >= len(sorted) {
-		index = len(sorted) - 1
+	trim := int(float64(len(sorted)) * trimPct / 100)
+	kept := sorted[trim : len(sorted)-trim]
+	var total time.Duration
+	for _, latency := range kept {
+		total += latency
 	}
+	return total / time.Duration(len(kept))
+}
+
+// nearestRankPercentile picks the smallest sample with at least p of the
+// samples at or below it, i.e. rank ceil(n*p). Indexing at n*p instead is
+// one sample too high whenever n*p is whole, which with clustered latencies
+// (e.g. 30% at 5ms, 70% at 3s) jumps p30 to the wrong cluster. The epsilon
+// absorbs float error such as 0.07*100 = 7.000000000000001.
+func nearestRankPercentile(sorted []time.Duration, percentile float64) time.Duration {
+	index := int(math.Ceil(float64(len(sorted))*percentile-1e-9)) - 1
+	index = min(max(index, 0), len(sorted)-1)
 	return sorted[index]
 }
+
+// linearPercentile interpolates between the two samples surrounding rank
+// (n-1)*p, i.e. the "inclusive" definition used by Excel's PERCENTILE.INC
+// and NumPy's default
+func linearPercentile(sorted []time.Duration, percentile float64) time.Duration {
+	rank := float64(len(sorted)-1) * percentile
+	lower := int(rank)
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	fraction := rank - float64(lower)
+	return sorted[lower] + time.Duration(fraction*float64(sorted[lower+1]-sorted[lower]))
+}
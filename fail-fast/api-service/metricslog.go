@@ -0,0 +1,35 @@
+// api-service/metricslog.go
+// periodic one-line metrics summary for terminal-only runs
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// Log a summary every interval until shutdown; 0 disables
+func startMetricsLogger(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	log.Printf("📝 Logging a metrics summary every %s", interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				logMetricsSummary()
+			case <-shutdownCtx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Works from a snapshot so the metrics lock isn't held while logging
+func logMetricsSummary() {
+	s := currentSnapshot()
+	log.Printf("📝 METRICS: requests=%d success=%.1f%% p99=%s circuit=%s fast_fails=%d",
+		s.TotalRequests, s.SuccessRate, s.p99, s.CircuitState, s.FastFails)
+}
@@ -0,0 +1,46 @@
+// api-service/metricsreset.go
+// manual metrics reset between measurement phases
+package main
+
+import (
+	"log"
+	"net/http"
+)
+
+const (
+	resetScopeAll     = "all"
+	resetScopeLatency = "latency"
+)
+
+// POST /metrics/reset?scope=all zeroes everything, as a trip does with
+// RESET_METRICS_ON_TRIP; scope=latency only clears the percentile samples
+// so the counters keep accumulating
+func handleMetricsReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	scope := r.URL.Query().Get("scope")
+	if scope == "" {
+		scope = resetScopeAll
+	}
+	if scope != resetScopeAll && scope != resetScopeLatency {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "scope must be " + resetScopeAll + " or " + resetScopeLatency,
+		})
+		return
+	}
+
+	metrics.mu.Lock()
+	if scope == resetScopeAll {
+		metrics.resetLocked()
+	} else {
+		metrics.resetLatencyLocked()
+	}
+	metrics.mu.Unlock()
+
+	log.Printf("🧹 Metrics reset (scope %s)", scope)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "reset", "scope": scope})
+}
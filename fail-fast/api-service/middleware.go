@@ -0,0 +1,82 @@
+// api-service/middleware.go
+// cross-cutting HTTP wrappers applied around every route
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+type contextKey string
+
+const requestIDKey contextKey = "request_id"
+
+// Client IDs end up in logs and downstream headers, so only short tokens of
+// safe characters are kept
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Tag each request with an ID (client-supplied X-Request-ID or generated)
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := clientRequestID(r.Header.Get("X-Request-ID"))
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+// The client's ID when it is valid, otherwise a new one
+func clientRequestID(id string) string {
+	if validRequestID.MatchString(id) {
+		return id
+	}
+	return newRequestID()
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+func requestID(r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// Turn handler panics into a logged, counted, clean JSON 500
+func withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				log.Printf("💥 PANIC [%s] %s %s: %v\n%s", requestID(r), r.Method, r.URL.Path, rec, debug.Stack())
+				recordPanic()
+
+				writeJSON(w, http.StatusInternalServerError, map[string]string{
+					"error":      "Internal server error",
+					"request_id": requestID(r),
+				})
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Serve cleartext HTTP/2 (h2c) alongside HTTP/1.1
+func withH2C(next http.Handler) http.Handler {
+	return h2c.NewHandler(next, &http2.Server{})
+}
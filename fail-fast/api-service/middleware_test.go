@@ -0,0 +1,49 @@
+// api-service/middleware_test.go
+// client-supplied request IDs are kept only when they are safe to log
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithRequestIDValidatesClientIDs(t *testing.T) {
+	var seen string
+	handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestID(r)
+	}))
+
+	tests := []struct {
+		name string
+		id   string
+		kept bool
+	}{
+		{"uuid", "3f2b8c1e-9d4a-4e7b-a1c2-0f9e8d7c6b5a", true},
+		{"dotted", "trace.42_a", true},
+		{"64 chars", strings.Repeat("a", 64), true},
+		{"empty", "", false},
+		{"65 chars", strings.Repeat("a", 65), false},
+		{"log injection", "abc\n💥 PANIC fake", false},
+		{"spaces", "a b", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/health", nil)
+			req.Header["X-Request-Id"] = []string{tc.id}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if (seen == tc.id) != tc.kept {
+				t.Errorf("request ID %q for client ID %q, kept = %t", seen, tc.id, tc.kept)
+			}
+			if !validRequestID.MatchString(seen) {
+				t.Errorf("request ID %q is not valid", seen)
+			}
+			if got := rec.Header().Get("X-Request-ID"); got != seen {
+				t.Errorf("response header %q, want %q", got, seen)
+			}
+		})
+	}
+}
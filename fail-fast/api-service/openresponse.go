@@ -0,0 +1,100 @@
+// api-service/openresponse.go
+// what checkouts get back while the circuit is open: fail mode and the templated 503 body
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// What an open circuit means for the caller: "closed" fast-fails with 503,
+// "open" skips the payment and answers with a degraded 200 confirmation
+// for paths where availability matters more than the charge
+const (
+	failModeClosed = "closed"
+	failModeOpen   = "open"
+)
+
+var (
+	openResponseTemplate = loadOpenResponseTemplate()
+	breakerFailMode      = getBreakerFailMode()
+
+	// When the circuit last opened and when this open period ends, used to
+	// estimate retry_after and to hold the jittered cooldown
+	openedAt   time.Time
+	openUntil  time.Time
+	openedAtMu sync.Mutex
+)
+
+// Load CB_OPEN_RESPONSE (a JSON document with {state}, {latency} and
+// {retry_after} placeholders) and make sure it renders to valid JSON
+func loadOpenResponseTemplate() string {
+	tmpl := getEnvString("CB_OPEN_RESPONSE", "")
+	if tmpl == "" {
+		return ""
+	}
+	if !json.Valid([]byte(renderOpenResponse(tmpl, "open", "1ms", 1))) {
+		log.Fatalf("❌ CB_OPEN_RESPONSE is not valid JSON once placeholders are filled: %s", tmpl)
+	}
+	log.Println("📝 Using custom open-circuit response template")
+	return tmpl
+}
+
+func getBreakerFailMode() string {
+	switch mode := getEnvString("BREAKER_FAIL_MODE", failModeClosed); mode {
+	case failModeClosed:
+		return failModeClosed
+	case failModeOpen:
+		log.Println("🔓 Failing open: open-circuit checkouts are confirmed without charging")
+		return failModeOpen
+	default:
+		log.Printf("⚠️ Unknown BREAKER_FAIL_MODE %q, using %s", mode, failModeClosed)
+		return failModeClosed
+	}
+}
+
+// Success-shaped reply used instead of the 503 when failing open
+func failOpenResult(item string, charged float64, latency time.Duration) CheckoutResult {
+	return CheckoutResult{http.StatusOK, map[string]interface{}{
+		"status":    "confirmed",
+		"item":      item,
+		"charged":   fmt.Sprintf("%.2f", charged),
+		"latency":   latency.String(),
+		"degraded":  true,
+		"fail_open": true,
+		"message":   "Payment skipped while the payment service is unavailable",
+	}}
+}
+
+func renderOpenResponse(tmpl, state, latency string, retryAfter int) string {
+	return strings.NewReplacer(
+		"{state}", state,
+		"{latency}", latency,
+		"{retry_after}", strconv.Itoa(retryAfter),
+	).Replace(tmpl)
+}
+
+func markCircuitOpened() {
+	openedAtMu.Lock()
+	defer openedAtMu.Unlock()
+	openedAt = time.Now()
+	openUntil = openedAt.Add(jitteredTimeout())
+}
+
+// Seconds until the breaker will let a half-open probe through
+func retryAfterSeconds() int {
+	openedAtMu.Lock()
+	defer openedAtMu.Unlock()
+	remaining := time.Until(openUntil)
+	if remaining <= 0 {
+		return 0
+	}
+	return int(math.Ceil(remaining.Seconds()))
+}
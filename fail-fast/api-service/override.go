@@ -0,0 +1,154 @@
+// api-service/override.go
+// manual override of the main breaker's state for demos and drills
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/sony/gobreaker"
+)
+
+// Override modes accepted by /circuit-state/force; "auto" hands control
+// back to gobreaker
+const (
+	overrideAuto     = "auto"
+	overrideOpen     = "open"
+	overrideClosed   = "closed"
+	overrideHalfOpen = "half-open"
+)
+
+var breakerOverride = &BreakerOverride{mode: overrideAuto}
+
+// Sits in front of the main breaker. gobreaker can't be pushed into a state,
+// so while an override is active checkouts bypass it: forced open rejects,
+// forced closed calls the downstream directly, and forced half-open lets
+// exactly breakerMaxRequests probes through. Like gobreaker, any failed
+// probe reopens; once every probe has succeeded the override settles on
+// closed. Probe outcomes are counted here, not in the breaker.
+type BreakerOverride struct {
+	mode           string
+	probesLeft     int
+	probesInFlight int
+	probeSuccesses int
+	probeFailures  int
+	mu             sync.Mutex
+}
+
+// Override state as served by /circuit-state/force
+type OverrideStats struct {
+	Mode           string `json:"mode"`
+	ProbesLeft     int    `json:"probes_left"`
+	ProbesInFlight int    `json:"probes_in_flight"`
+	ProbeSuccesses int    `json:"probe_successes"`
+	ProbeFailures  int    `json:"probe_failures"`
+}
+
+// Decide how a checkout on the main breaker proceeds: an error to fail with,
+// or the active mode (overrideAuto meaning "use the breaker")
+func (o *BreakerOverride) admit() (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	switch o.mode {
+	case overrideOpen:
+		return o.mode, gobreaker.ErrOpenState
+	case overrideHalfOpen:
+		if o.probesLeft == 0 {
+			return o.mode, gobreaker.ErrTooManyRequests
+		}
+		o.probesLeft--
+		o.probesInFlight++
+	}
+	return o.mode, nil
+}
+
+// Record a forced probe's outcome and re-evaluate the override
+func (o *BreakerOverride) probeDone(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.probesInFlight--
+	if isBreakerSuccess(err) {
+		o.probeSuccesses++
+	} else {
+		o.probeFailures++
+		if o.mode == overrideHalfOpen {
+			o.mode, o.probesLeft = overrideOpen, 0
+			log.Printf("🕹️ Forced probe failed, override → %s: %v", overrideOpen, err)
+		}
+	}
+	if o.mode == overrideHalfOpen && o.probesLeft == 0 && o.probesInFlight == 0 {
+		o.mode = overrideClosed
+		log.Printf("🕹️ All forced probes succeeded, override → %s", overrideClosed)
+	}
+}
+
+// Run one forced probe. It is always accounted for: a panic counts as a
+// failed probe and is then re-raised, as gobreaker does.
+func (o *BreakerOverride) probe(fn func() (interface{}, error)) (result interface{}, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			o.probeDone(fmt.Errorf("panic: %v", rec))
+			panic(rec)
+		}
+		o.probeDone(err)
+	}()
+	return fn()
+}
+
+func (o *BreakerOverride) set(mode string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.mode = mode
+	o.probesLeft = 0
+	if mode == overrideHalfOpen {
+		o.probesLeft = int(breakerMaxRequests)
+	}
+	log.Printf("🕹️ Breaker override → %s", mode)
+}
+
+func (o *BreakerOverride) stats() OverrideStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return OverrideStats{
+		Mode:           o.mode,
+		ProbesLeft:     o.probesLeft,
+		ProbesInFlight: o.probesInFlight,
+		ProbeSuccesses: o.probeSuccesses,
+		ProbeFailures:  o.probeFailures,
+	}
+}
+
+// GET shows the override; POST {"state": "open|closed|half-open|auto"} sets
+// it and, like /admin/flags, requires the admin token
+func handleForceState(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, breakerOverride.stats())
+	case http.MethodPost:
+		if !isAdmin(r) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "Admin token required"})
+			return
+		}
+		var body struct {
+			State string `json:"state"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": `Expected {"state": "open|closed|half-open|auto"}`})
+			return
+		}
+		switch body.State {
+		case overrideAuto, overrideOpen, overrideClosed, overrideHalfOpen:
+			breakerOverride.set(body.State)
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Unknown state " + body.State})
+			return
+		}
+		writeJSON(w, http.StatusOK, breakerOverride.stats())
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,48 @@
+// api-service/override_test.go
+// forced half-open probes are accounted for even when they panic
+package main
+
+import "testing"
+
+func TestOverrideProbePanicFailsProbe(t *testing.T) {
+	override := &BreakerOverride{mode: overrideAuto}
+	override.set(overrideHalfOpen)
+	if _, err := override.admit(); err != nil {
+		t.Fatal(err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the probe's panic was swallowed")
+			}
+		}()
+		override.probe(func() (interface{}, error) { panic("boom") })
+	}()
+
+	stats := override.stats()
+	if stats.ProbesInFlight != 0 {
+		t.Errorf("%d probes in flight after the panic, want 0", stats.ProbesInFlight)
+	}
+	if stats.ProbeFailures != 1 || stats.Mode != overrideOpen {
+		t.Errorf("got %+v, want one failed probe and the override open", stats)
+	}
+}
+
+func TestOverrideProbeSuccessCloses(t *testing.T) {
+	override := &BreakerOverride{mode: overrideAuto}
+	override.set(overrideHalfOpen)
+	for i := 0; i < int(breakerMaxRequests); i++ {
+		if _, err := override.admit(); err != nil {
+			t.Fatal(err)
+		}
+		if result, err := override.probe(func() (interface{}, error) { return "ok", nil }); result != "ok" || err != nil {
+			t.Fatalf("probe returned %v, %v", result, err)
+		}
+	}
+
+	stats := override.stats()
+	if stats.Mode != overrideClosed || stats.ProbesInFlight != 0 || stats.ProbeSuccesses != int(breakerMaxRequests) {
+		t.Errorf("got %+v, want every probe succeeded and the override closed", stats)
+	}
+}
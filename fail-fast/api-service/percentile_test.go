@@ -0,0 +1,208 @@
+// api-service/percentile_test.go
+// nearest-rank and linear percentiles against hand-computed and exact values
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func withPercentileMethod(t *testing.T, method string) {
+	t.Helper()
+	saved := percentileMethod
+	t.Cleanup(func() { percentileMethod = saved })
+	percentileMethod = method
+}
+
+func millisList(values ...float64) []time.Duration {
+	latencies := make([]time.Duration, len(values))
+	for i, v := range values {
+		latencies[i] = time.Duration(v * float64(time.Millisecond))
+	}
+	return latencies
+}
+
+func TestSmallSamplePercentiles(t *testing.T) {
+	ten := millisList(100, 30, 80, 10, 60, 20, 90, 50, 70, 40) // unsorted on purpose
+	tests := []struct {
+		name        string
+		samples     []time.Duration
+		percentile  float64
+		nearestRank time.Duration
+		linear      time.Duration
+	}{
+		{"n=1 p50", millisList(7), 0.50, 7 * time.Millisecond, 7 * time.Millisecond},
+		{"n=1 p99", millisList(7), 0.99, 7 * time.Millisecond, 7 * time.Millisecond},
+
+		{"n=2 p50", millisList(20, 10), 0.50, 10 * time.Millisecond, 15 * time.Millisecond},
+		{"n=2 p95", millisList(20, 10), 0.95, 20 * time.Millisecond, 19500 * time.Microsecond},
+		{"n=2 p99", millisList(20, 10), 0.99, 20 * time.Millisecond, 19900 * time.Microsecond},
+
+		{"n=4 p25", millisList(40, 10, 30, 20), 0.25, 10 * time.Millisecond, 17500 * time.Microsecond},
+		{"n=4 p50", millisList(40, 10, 30, 20), 0.50, 20 * time.Millisecond, 25 * time.Millisecond},
+		{"n=4 p75", millisList(40, 10, 30, 20), 0.75, 30 * time.Millisecond, 32500 * time.Microsecond},
+		{"n=4 p95", millisList(40, 10, 30, 20), 0.95, 40 * time.Millisecond, 38500 * time.Microsecond},
+
+		{"n=10 p10", ten, 0.10, 10 * time.Millisecond, 19 * time.Millisecond},
+		{"n=10 p50", ten, 0.50, 50 * time.Millisecond, 55 * time.Millisecond},
+		{"n=10 p90", ten, 0.90, 90 * time.Millisecond, 91 * time.Millisecond},
+		{"n=10 p95", ten, 0.95, 100 * time.Millisecond, 95500 * time.Microsecond},
+		{"n=10 p99", ten, 0.99, 100 * time.Millisecond, 99100 * time.Microsecond},
+		{"n=10 p100", ten, 1.00, 100 * time.Millisecond, 100 * time.Millisecond},
+	}
+
+	// Interpolation goes through float64, so allow a nanosecond of rounding
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for method, want := range map[string]time.Duration{
+				percentileNearestRank: tc.nearestRank,
+				percentileLinear:      tc.linear,
+			} {
+				withPercentileMethod(t, method)
+				if got := calculatePercentile(tc.samples, tc.percentile); got < want-1 || got > want+1 {
+					t.Errorf("%s: got %s, want %s", method, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPercentileOfNoSamples(t *testing.T) {
+	for _, method := range []string{percentileNearestRank, percentileLinear} {
+		withPercentileMethod(t, method)
+		if got := calculatePercentile(nil, 0.99); got != 0 {
+			t.Errorf("%s: got %s for no samples, want 0", method, got)
+		}
+	}
+}
+
+// 10k log-normal samples (median 50ms, sigma 1). Each method must match its
+// definition computed directly from the sorted samples, and both must land
+// near the distribution's true quantiles, exp(ln 50ms + z_p).
+func TestLogNormalPercentiles(t *testing.T) {
+	samples := logNormalLatencies(10000, 3)
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	n := len(sorted)
+
+	quantiles := []struct {
+		percentile float64
+		z          float64 // standard normal quantile
+		tolerance  float64 // relative, several standard errors at n=10k
+	}{
+		{0.50, 0, 0.05},
+		{0.90, 1.2815516, 0.06},
+		{0.95, 1.6448536, 0.08},
+		{0.99, 2.3263479, 0.15},
+	}
+	for _, q := range quantiles {
+		// Nearest rank: the smallest sample with at least p*n samples at or below it
+		var exactNearest time.Duration
+		for i, latency := range sorted {
+			if float64(i+1) >= q.percentile*float64(n)-1e-9 {
+				exactNearest = latency
+				break
+			}
+		}
+		// Linear: interpolate between order statistics at rank (n-1)*p
+		rank := q.percentile * float64(n-1)
+		below, above := sorted[int(math.Floor(rank))], sorted[int(math.Ceil(rank))]
+		exactLinear := below + time.Duration((rank-math.Floor(rank))*float64(above-below))
+
+		withPercentileMethod(t, percentileNearestRank)
+		nearest := calculatePercentile(samples, q.percentile)
+		if nearest != exactNearest {
+			t.Errorf("p%g nearest-rank: got %s, want %s", q.percentile*100, nearest, exactNearest)
+		}
+		withPercentileMethod(t, percentileLinear)
+		linear := calculatePercentile(samples, q.percentile)
+		if diff := linear - exactLinear; diff < -1 || diff > 1 {
+			t.Errorf("p%g linear: got %s, want %s", q.percentile*100, linear, exactLinear)
+		}
+
+		truth := math.Exp(math.Log(50e6) + q.z)
+		for method, got := range map[string]time.Duration{percentileNearestRank: nearest, percentileLinear: linear} {
+			if rel := math.Abs(float64(got)-truth) / truth; rel > q.tolerance {
+				t.Errorf("p%g %s: %s is %.1f%% from the true %s", q.percentile*100, method,
+					got, rel*100, time.Duration(truth))
+			}
+		}
+	}
+}
+
+// Same samples through the LATENCY_HISTOGRAM path that /metrics uses
+func TestLogNormalPercentilesFromHistogram(t *testing.T) {
+	saved := latencyHistogramSigfigs
+	t.Cleanup(func() { latencyHistogramSigfigs = saved })
+	latencyHistogramSigfigs = 3
+	withPercentileMethod(t, percentileNearestRank)
+
+	samples := logNormalLatencies(10000, 3)
+	hist := newLatencySamples()
+	if hist.hist == nil {
+		t.Fatal("newLatencySamples ignored latencyHistogramSigfigs")
+	}
+	for _, latency := range samples {
+		hist.add(latency)
+	}
+
+	fractions := []float64{0.50, 0.90, 0.95, 0.99}
+	got := hist.percentiles(fractions...)
+	for i, fraction := range fractions {
+		want := calculatePercentile(samples, fraction)
+		tolerance := time.Duration(float64(want)*0.001) + time.Microsecond
+		if diff := got[i] - want; diff < -tolerance || diff > tolerance {
+			t.Errorf("p%g: histogram %s, exact %s (tolerance %s)", fraction*100, got[i], want, tolerance)
+		}
+	}
+}
+
+// 30% of calls fail fast at 5ms and 70% time out at 3s. Nearest rank must
+// keep p30 in the fast cluster and everything past it in the slow one.
+func TestNearestRankWithDuplicateClusters(t *testing.T) {
+	withPercentileMethod(t, percentileNearestRank)
+	const fast, slow = 5 * time.Millisecond, 3 * time.Second
+
+	for _, n := range []int{10, 100, 1000} {
+		samples := make([]time.Duration, 0, n)
+		for i := 0; i < n; i++ {
+			if i < n*3/10 {
+				samples = append(samples, fast)
+			} else {
+				samples = append(samples, slow)
+			}
+		}
+		rand.New(rand.NewSource(int64(n))).Shuffle(n, func(i, j int) {
+			samples[i], samples[j] = samples[j], samples[i]
+		})
+		sorted := sortedLatencies(samples)
+		lastFast := n*3/10 - 1
+		if sorted[lastFast] != fast || sorted[lastFast+1] != slow {
+			t.Fatalf("n=%d: clusters don't meet at index %d", n, lastFast)
+		}
+
+		for _, tc := range []struct {
+			percentile float64
+			index      int
+			want       time.Duration
+		}{
+			{0.01, max(n/100-1, 0), fast},
+			{0.30, lastFast, fast},                    // last fast sample
+			{0.30 + 1/float64(n), lastFast + 1, slow}, // first slow sample
+			{0.50, n/2 - 1, slow},
+			{0.95, n*95/100 - 1, slow},
+			{0.99, n*99/100 - 1, slow},
+			{1.00, n - 1, slow},
+		} {
+			if got := calculatePercentile(samples, tc.percentile); got != tc.want {
+				t.Errorf("n=%d p%g: got %s, want %s", n, tc.percentile*100, got, tc.want)
+			}
+			if got := nearestRankPercentile(sorted, tc.percentile); got != sorted[tc.index] {
+				t.Errorf("n=%d p%g: got %s, want sorted[%d] = %s", n, tc.percentile*100, got, tc.index, sorted[tc.index])
+			}
+		}
+	}
+}
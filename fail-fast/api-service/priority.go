@@ -0,0 +1,99 @@
+// api-service/priority.go
+// X-Priority quality of service: a lenient breaker for high-priority
+// checkouts and preferential shedding of everything else under stress
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+const (
+	priorityHigh   = "high"
+	priorityNormal = "normal"
+)
+
+var (
+	priorityEnabled = getEnvBool("PRIORITY_BREAKERS", false)
+
+	// The high-priority breaker trips only on a longer failure streak or a
+	// higher failure ratio than the shared one
+	highTripConsecutiveFailures = uint32(getEnvInt("CB_HIGH_PRIORITY_CONSECUTIVE_FAILURES", 2*tripConsecutiveFailures))
+	highTripFailureRatio        = getEnvFloat("CB_HIGH_PRIORITY_FAILURE_RATIO", 0.8)
+
+	// Normal-priority checkouts are shed while the shared breaker isn't
+	// closed or the recent success rate (percent) is below this
+	priorityShedSuccessRate = getEnvFloat("PRIORITY_SHED_SUCCESS_RATE", 50)
+
+	highPriorityCB = newHighPriorityBreaker()
+	priorityShed   atomic.Int64
+)
+
+// Priority QoS as reported in /metrics
+type PriorityStats struct {
+	Shed       int64                     `json:"shed"`
+	ByPriority map[string]CohortSnapshot `json:"by_priority"`
+}
+
+// nil unless PRIORITY_BREAKERS=true
+func newHighPriorityBreaker() *gobreaker.CircuitBreaker {
+	if !priorityEnabled {
+		return nil
+	}
+	settings := breakerSettings("payment-service/high-priority")
+	settings.ReadyToTrip = lenientReadyToTrip
+	settings.OnStateChange = func(name string, from gobreaker.State, to gobreaker.State) {
+		log.Printf("🔌 STATE CHANGE [high priority]: %s → %s", from, to)
+	}
+	log.Printf("🎟️ Priority breakers: high priority trips after %d consecutive failures or %.0f%% failing; normal traffic shed below %.0f%% recent success",
+		highTripConsecutiveFailures, highTripFailureRatio*100, priorityShedSuccessRate)
+	return gobreaker.NewCircuitBreaker(settings)
+}
+
+func lenientReadyToTrip(counts gobreaker.Counts) bool {
+	if counts.ConsecutiveFailures >= highTripConsecutiveFailures {
+		return true
+	}
+	if counts.Requests >= uint32(breakerMinRequests) {
+		return float64(counts.TotalFailures)/float64(counts.Requests) >= highTripFailureRatio
+	}
+	return false
+}
+
+// "high" for X-Priority: high, "normal" for anything else
+func requestPriority(r *http.Request) string {
+	if strings.EqualFold(strings.TrimSpace(r.Header.Get("X-Priority")), priorityHigh) {
+		return priorityHigh
+	}
+	return priorityNormal
+}
+
+// High-priority checkouts skip shedding and the slow-start ramp
+func bypassesShedding(r *http.Request) bool {
+	return priorityEnabled && requestPriority(r) == priorityHigh
+}
+
+// Shed a normal-priority checkout while the downstream looks stressed, so
+// the capacity that is left goes to high-priority traffic
+func shedLowPriority(r *http.Request) bool {
+	if !priorityEnabled || requestPriority(r) == priorityHigh {
+		return false
+	}
+	// Read the breaker before taking the metrics lock
+	stressed := cb.State() != gobreaker.StateClosed
+	if !stressed {
+		metrics.mu.Lock()
+		rate, samples := metrics.Recent.successRate(time.Now(), recentWindow)
+		metrics.mu.Unlock()
+		stressed = samples >= breakerMinRequests && rate < priorityShedSuccessRate
+	}
+	if stressed {
+		priorityShed.Add(1)
+	}
+	return stressed
+}
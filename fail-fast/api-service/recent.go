@@ -0,0 +1,91 @@
+// api-service/recent.go
+// sliding window of recent checkouts for "right now" views next to all-time ones
+package main
+
+import "time"
+
+var (
+	// Length of the recent window behind p95_recent and /ready's success rate
+	recentWindow = getEnvDuration("RECENT_WINDOW", time.Minute)
+
+	// Window behind the /scale-signal p99
+	scaleSignalWindow = getEnvDuration("SCALE_SIGNAL_WINDOW", recentWindow)
+)
+
+// Timestamped samples, oldest first, kept for the longest window any view
+// needs. Not safe for concurrent use: it lives in Metrics and is guarded by
+// metrics.mu.
+type RecentWindow struct {
+	retain  time.Duration
+	samples []recentSample
+}
+
+type recentSample struct {
+	at      time.Time
+	latency time.Duration
+	success bool
+}
+
+func newRecentWindow(windows ...time.Duration) *RecentWindow {
+	w := &RecentWindow{}
+	for _, window := range windows {
+		w.retain = max(w.retain, window)
+	}
+	return w
+}
+
+func (w *RecentWindow) add(now time.Time, latency time.Duration, success bool) {
+	w.prune(now)
+	w.samples = append(w.samples, recentSample{at: now, latency: latency, success: success})
+}
+
+// Drop samples that have aged out of every window
+func (w *RecentWindow) prune(now time.Time) {
+	cutoff := now.Add(-w.retain)
+	i := 0
+	for i < len(w.samples) && w.samples[i].at.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		w.samples = append(w.samples[:0], w.samples[i:]...)
+	}
+}
+
+// Samples from the last window
+func (w *RecentWindow) within(now time.Time, window time.Duration) []recentSample {
+	w.prune(now)
+	cutoff := now.Add(-window)
+	i := 0
+	for i < len(w.samples) && w.samples[i].at.Before(cutoff) {
+		i++
+	}
+	return w.samples[i:]
+}
+
+func (w *RecentWindow) latencies(now time.Time, window time.Duration) []time.Duration {
+	samples := w.within(now, window)
+	latencies := make([]time.Duration, len(samples))
+	for i, s := range samples {
+		latencies[i] = s.latency
+	}
+	return latencies
+}
+
+// Success rate (0-100) over the window and the number of samples behind it
+func (w *RecentWindow) successRate(now time.Time, window time.Duration) (float64, int) {
+	samples := w.within(now, window)
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	successes := 0
+	for _, s := range samples {
+		if s.success {
+			successes++
+		}
+	}
+	return float64(successes) / float64(len(samples)) * 100, len(samples)
+}
+
+func (w *RecentWindow) reset() {
+	w.samples = nil
+}
@@ -0,0 +1,56 @@
+// api-service/responsefields.go
+// optional extra fields on the checkout success response
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// Fields that RESPONSE_FIELDS can add on top of the default success body
+const (
+	fieldRequestID    = "request_id"
+	fieldCircuitState = "circuit_state"
+	fieldTimestamp    = "timestamp"
+	fieldOrderID      = "order_id"
+)
+
+// Comma-separated RESPONSE_FIELDS, e.g. "request_id,order_id"; empty keeps
+// the default status/item/charged/latency body
+var responseFields = getResponseFields()
+
+func getResponseFields() []string {
+	var fields []string
+	for _, field := range strings.Split(getEnvString("RESPONSE_FIELDS", ""), ",") {
+		switch field = strings.TrimSpace(field); field {
+		case "":
+		case fieldRequestID, fieldCircuitState, fieldTimestamp, fieldOrderID:
+			fields = append(fields, field)
+		default:
+			log.Printf("⚠️ Unknown RESPONSE_FIELDS entry %q, ignoring", field)
+		}
+	}
+	if len(fields) > 0 {
+		log.Printf("🧾 Adding %s to checkout success responses", strings.Join(fields, ", "))
+	}
+	return fields
+}
+
+func addResponseFields(response map[string]interface{}, r *http.Request, state gobreaker.State) {
+	for _, field := range responseFields {
+		switch field {
+		case fieldRequestID:
+			response[field] = requestID(r)
+		case fieldCircuitState:
+			response[field] = state.String()
+		case fieldTimestamp:
+			response[field] = time.Now().UTC().Format(time.RFC3339Nano)
+		case fieldOrderID:
+			response[field] = "ord_" + newRequestID()
+		}
+	}
+}
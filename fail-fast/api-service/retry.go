@@ -0,0 +1,103 @@
+// api-service/retry.go
+// downstream retries limited by a budget shared across all requests
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Cap on banked retry tokens, so a long quiet spell can't fund a retry storm
+const maxRetryTokens = 10
+
+var (
+	maxRetries   = getEnvInt("MAX_RETRIES", 0)
+	retryBackoff = getEnvDuration("RETRY_BACKOFF", 100*time.Millisecond)
+	retryBudget  = newRetryBudget(getEnvFloat("RETRY_BUDGET_RATIO", 0.1))
+
+	// With RETRY_IDEMPOTENT_ONLY=true a failed payment call is only retried
+	// for checkouts marked idempotent, so a retry can never double-charge
+	retryIdempotentOnly = getEnvBool("RETRY_IDEMPOTENT_ONLY", false)
+)
+
+// A checkout is idempotent when X-Idempotent says so; without that header,
+// when it carries an Idempotency-Key or uses a safe method (GET, HEAD)
+func isIdempotentRequest(r *http.Request) bool {
+	if marked, err := strconv.ParseBool(r.Header.Get("X-Idempotent")); err == nil {
+		return marked
+	}
+	if r.Header.Get("Idempotency-Key") != "" {
+		return true
+	}
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
+
+// Whether failed payment calls for this checkout may be retried at all
+func retriesAllowed(r *http.Request) bool {
+	return !retryIdempotentOnly || isIdempotentRequest(r)
+}
+
+// Token bucket: every request deposits `ratio` tokens and every retry costs
+// one, so retries can never exceed roughly ratio × requests
+type RetryBudget struct {
+	ratio    float64
+	tokens   float64
+	requests int
+	retries  int
+	denied   int
+	mu       sync.Mutex
+}
+
+// Budget consumption as reported in /metrics
+type RetryBudgetStats struct {
+	Ratio           float64 `json:"ratio"`
+	TokensAvailable float64 `json:"tokens_available"`
+	Requests        int     `json:"requests"`
+	RetriesAllowed  int     `json:"retries_allowed"`
+	RetriesDenied   int     `json:"retries_denied"`
+}
+
+func newRetryBudget(ratio float64) *RetryBudget {
+	if maxRetries > 0 {
+		log.Printf("🪙 Up to %d retries per request, budget ratio %.2f", maxRetries, ratio)
+	}
+	return &RetryBudget{ratio: ratio, tokens: maxRetryTokens}
+}
+
+func (b *RetryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests++
+	b.tokens += b.ratio
+	if b.tokens > maxRetryTokens {
+		b.tokens = maxRetryTokens
+	}
+}
+
+// Take a token for one retry; false means the budget is spent
+func (b *RetryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		b.denied++
+		return false
+	}
+	b.tokens--
+	b.retries++
+	return true
+}
+
+func (b *RetryBudget) stats() RetryBudgetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return RetryBudgetStats{
+		Ratio:           b.ratio,
+		TokensAvailable: b.tokens,
+		Requests:        b.requests,
+		RetriesAllowed:  b.retries,
+		RetriesDenied:   b.denied,
+	}
+}
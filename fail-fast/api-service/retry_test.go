@@ -0,0 +1,119 @@
+// api-service/retry_test.go
+// retries and backoff against a stub payment service
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// Stub payment service answering every call with status and a short text
+// body, counting calls
+func countingServer(t *testing.T, status int) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(status)
+		w.Write([]byte(http.StatusText(status)))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// Up to retries with the given backoff and a full budget, restored afterwards
+func withRetries(t *testing.T, retries int, backoff time.Duration) {
+	t.Helper()
+	savedRetries, savedBackoff, savedBudget := maxRetries, retryBackoff, retryBudget
+	t.Cleanup(func() { maxRetries, retryBackoff, retryBudget = savedRetries, savedBackoff, savedBudget })
+	maxRetries, retryBackoff, retryBudget = retries, backoff, newRetryBudget(1)
+}
+
+// Point checkouts at baseURL through a fresh main breaker
+func withPaymentService(t *testing.T, baseURL string) {
+	t.Helper()
+	savedURL, savedCB := flakyServiceURL, cb
+	t.Cleanup(func() { flakyServiceURL, cb = savedURL, savedCB })
+	flakyServiceURL = baseURL
+	cb = gobreaker.NewCircuitBreaker(breakerSettings("payment-service"))
+}
+
+func checkout(method string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/checkout", strings.NewReader(`{"item":"costume","price":10}`))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	handleCheckout(rec, req)
+	return rec
+}
+
+// With RETRY_IDEMPOTENT_ONLY a plain POST gets exactly one attempt and no
+// backoff; anything marked idempotent is retried up to MAX_RETRIES
+func TestRetriesOnlyForIdempotentCheckouts(t *testing.T) {
+	saved := retryIdempotentOnly
+	t.Cleanup(func() { retryIdempotentOnly = saved })
+	retryIdempotentOnly = true
+
+	tests := []struct {
+		name   string
+		method string
+		header http.Header
+		calls  int64
+	}{
+		{"plain POST", http.MethodPost, nil, 1},
+		{"POST marked not idempotent", http.MethodPost, http.Header{"X-Idempotent": {"false"}}, 1},
+		{"POST marked idempotent", http.MethodPost, http.Header{"X-Idempotent": {"true"}}, 4},
+		{"GET", http.MethodGet, nil, 4},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server, calls := countingServer(t, http.StatusInternalServerError)
+			withPaymentService(t, server.URL)
+			withRetries(t, 3, 100*time.Millisecond)
+
+			start := time.Now()
+			rec := checkout(tc.method, tc.header)
+			elapsed := time.Since(start)
+
+			if rec.Code == http.StatusOK {
+				t.Fatalf("status 200 from a failing payment service")
+			}
+			if calls.Load() != tc.calls {
+				t.Errorf("downstream calls = %d, want %d", calls.Load(), tc.calls)
+			}
+			// Backoff is 100ms, 200ms, 300ms; a single attempt never waits
+			if tc.calls == 1 && elapsed >= retryBackoff {
+				t.Errorf("non-idempotent failure took %s, want an immediate answer", elapsed)
+			}
+		})
+	}
+}
+
+func TestRetryBackoffStopsWhenContextEnds(t *testing.T) {
+	server, calls := countingServer(t, http.StatusInternalServerError)
+	withRetries(t, 3, time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, retries, err := callPaymentService(ctx, server.URL, 10, true)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("expected the 500 to be returned")
+	}
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("returned after %s, want the 50ms deadline rather than the 1s backoff", elapsed)
+	}
+	if retries != 0 || calls.Load() != 1 {
+		t.Errorf("retries = %d, downstream calls = %d; want 0 and 1", retries, calls.Load())
+	}
+}
@@ -0,0 +1,52 @@
+// api-service/routes.go
+// route registry: every endpoint is registered with a description so
+// /debug/endpoints can list them
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// One registered endpoint. Methods document what the handler expects; the
+// handler itself is responsible for rejecting others.
+type RouteInfo struct {
+	Path        string   `json:"path"`
+	Methods     []string `json:"methods"`
+	Description string   `json:"description"`
+}
+
+var routes = &RouteRegistry{mux: http.NewServeMux()}
+
+// Routes live on their own mux rather than http.DefaultServeMux, which
+// imported packages (e.g. golang.org/x/net/trace via gRPC) register
+// /debug/* handlers on
+type RouteRegistry struct {
+	mux    *http.ServeMux
+	routes []RouteInfo
+	mu     sync.Mutex
+}
+
+// Register a handler on the service's mux and record its metadata
+func handleRoute(path string, methods []string, description string, handler http.HandlerFunc) {
+	routes.mux.HandleFunc(path, handler)
+	routes.mu.Lock()
+	defer routes.mu.Unlock()
+	routes.routes = append(routes.routes, RouteInfo{Path: path, Methods: methods, Description: description})
+}
+
+// Routes sorted by path
+func (reg *RouteRegistry) list() []RouteInfo {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	listed := append([]RouteInfo(nil), reg.routes...)
+	sort.Slice(listed, func(i, j int) bool {
+		return listed[i].Path < listed[j].Path
+	})
+	return listed
+}
+
+func handleDebugEndpoints(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"endpoints": routes.list()})
+}
@@ -0,0 +1,95 @@
+// api-service/routing.go
+// canary split between breaker-protected and direct downstream calls
+package main
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+const (
+	routeBreaker = "breaker"
+	routeDirect  = "direct"
+)
+
+// Percentage of checkouts sent through the breaker; the rest call the
+// payment service directly so the two cohorts can be compared
+var breakerTrafficPct = getEnvFloat("BREAKER_TRAFFIC_PCT", 100)
+
+// ENABLE_BREAKER=false sends every checkout down the direct route, the
+// unprotected baseline that the breaker is measured against
+var breakerEnabled = getEnvBool("ENABLE_BREAKER", true)
+
+func chooseRoute() string {
+	if !breakerEnabled {
+		// UNPROTECTED: no breaker, every failure waits out the full timeout
+		return routeDirect
+	}
+	if breakerTrafficPct >= 100 || rand.Float64()*100 < breakerTrafficPct {
+		return routeBreaker
+	}
+	return routeDirect
+}
+
+// Per-route counters behind the /metrics cohorts
+type CohortMetrics struct {
+	Requests     int
+	Successes    int
+	Failures     int
+	FastFails    int
+	TotalLatency time.Duration
+	Latencies    *LatencySamples
+}
+
+type CohortSnapshot struct {
+	Requests    int     `json:"requests"`
+	Successes   int     `json:"successes"`
+	Failures    int     `json:"failures"`
+	FastFails   int     `json:"fast_fails"`
+	SuccessRate float64 `json:"success_rate"`
+	AvgLatency  *string `json:"avg_latency"`
+	P95Latency  *string `json:"p95_latency"`
+	P99Latency  *string `json:"p99_latency"`
+}
+
+// Count one outcome against its cohort, creating it on first use; the
+// caller must hold metrics.mu
+func recordCohort(cohorts map[string]*CohortMetrics, key string, err error, latency time.Duration) {
+	cohort := cohorts[key]
+	if cohort == nil {
+		cohort = &CohortMetrics{Latencies: newLatencySamples()}
+		cohorts[key] = cohort
+	}
+	cohort.Requests++
+	cohort.TotalLatency += latency
+	cohort.Latencies.add(latency)
+	switch {
+	case isDeclined(err):
+	case err != nil:
+		cohort.Failures++
+		if err == gobreaker.ErrOpenState {
+			cohort.FastFails++
+		}
+	default:
+		cohort.Successes++
+	}
+}
+
+func (c *CohortMetrics) snapshot() CohortSnapshot {
+	snapshot := CohortSnapshot{
+		Requests:  c.Requests,
+		Successes: c.Successes,
+		Failures:  c.Failures,
+		FastFails: c.FastFails,
+	}
+	if c.Requests > 0 {
+		snapshot.SuccessRate = float64(c.Successes) / float64(c.Requests) * 100
+		snapshot.AvgLatency = durationString(c.TotalLatency / time.Duration(c.Requests))
+		values := c.Latencies.percentiles(0.95, 0.99)
+		snapshot.P95Latency = durationString(values[0])
+		snapshot.P99Latency = durationString(values[1])
+	}
+	return snapshot
+}
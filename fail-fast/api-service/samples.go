@@ -0,0 +1,113 @@
+// api-service/samples.go
+// sampled ring buffer of recent checkout request/response pairs for /debug/requests
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// nil unless REQUEST_SAMPLE_SIZE is set. Memory is bounded by size entries
+// of at most two bodies of REQUEST_SAMPLE_BODY_LIMIT bytes each.
+var requestSamples = newSampleBuffer(
+	getEnvInt("REQUEST_SAMPLE_SIZE", 0),
+	getEnvFloat("REQUEST_SAMPLE_RATE", 1),
+	getEnvInt("REQUEST_SAMPLE_BODY_LIMIT", 1024),
+)
+
+// One sampled checkout; bodies are redacted and truncated
+type RequestSample struct {
+	Time         time.Time `json:"time"`
+	RequestID    string    `json:"request_id"`
+	RequestBody  string    `json:"request_body"`
+	Status       int       `json:"status"`
+	ResponseBody string    `json:"response_body"`
+	Latency      string    `json:"latency"`
+	CircuitState string    `json:"circuit_state"`
+	Error        string    `json:"error,omitempty"`
+}
+
+type SampleBuffer struct {
+	samples   []RequestSample
+	next      int
+	full      bool
+	rate      float64
+	bodyLimit int
+	mu        sync.Mutex
+}
+
+func newSampleBuffer(size int, rate float64, bodyLimit int) *SampleBuffer {
+	if size <= 0 {
+		return nil
+	}
+	rate = min(max(rate, 0), 1)
+	bodyLimit = max(bodyLimit, 1)
+	log.Printf("🧪 Sampling %.0f%% of checkouts into the last %d at /debug/requests (bodies capped at %d bytes)",
+		rate*100, size, bodyLimit)
+	return &SampleBuffer{samples: make([]RequestSample, size), rate: rate, bodyLimit: bodyLimit}
+}
+
+// Keep a sampled checkout. response is the JSON body or a plain-text message.
+func (s *SampleBuffer) maybeRecord(r *http.Request, body []byte, status int, response interface{}, latency time.Duration) {
+	if s == nil || rand.Float64() >= s.rate {
+		return
+	}
+
+	sample := RequestSample{
+		Time:         time.Now(),
+		RequestID:    requestID(r),
+		RequestBody:  redactBody(body, s.bodyLimit),
+		Status:       status,
+		Latency:      latency.String(),
+		CircuitState: requestBreakerState(r).String(),
+	}
+	switch response := response.(type) {
+	case string:
+		sample.ResponseBody = redactBody([]byte(response), s.bodyLimit)
+		if status >= http.StatusBadRequest {
+			sample.Error = response
+		}
+	default:
+		if encoded, err := json.Marshal(response); err == nil {
+			sample.ResponseBody = redactBody(encoded, s.bodyLimit)
+		}
+		if fields, ok := response.(map[string]string); ok {
+			sample.Error = fields["error"]
+		} else if fields, ok := response.(map[string]interface{}); ok {
+			sample.Error, _ = fields["error"].(string)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % len(s.samples)
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+// Samples oldest first
+func (s *SampleBuffer) list() []RequestSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.full {
+		return append([]RequestSample(nil), s.samples[:s.next]...)
+	}
+	return append(append([]RequestSample(nil), s.samples[s.next:]...), s.samples[:s.next]...)
+}
+
+func handleDebugRequests(w http.ResponseWriter, r *http.Request) {
+	if requestSamples == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Request sampling is disabled; set REQUEST_SAMPLE_SIZE"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"rate":     requestSamples.rate,
+		"requests": requestSamples.list(),
+	})
+}
@@ -0,0 +1,28 @@
+// api-service/scalesignal.go
+// single-number autoscaling signal: windowed p99 latency for an HPA to poll
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Plain-text p99 in milliseconds over SCALE_SIGNAL_WINDOW, 0 with no recent
+// traffic; ?format=json adds the window and sample count
+func handleScaleSignal(w http.ResponseWriter, r *http.Request) {
+	metrics.mu.Lock()
+	value, samples := metrics.recentPercentileLocked(time.Now(), scaleSignalWindow, 0.99)
+	metrics.mu.Unlock()
+
+	p99 := millis(value)
+	if r.URL.Query().Get("format") == "json" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"p99_ms":  p99,
+			"window":  scaleSignalWindow.String(),
+			"samples": samples,
+		})
+		return
+	}
+	writeText(w, http.StatusOK, strconv.FormatFloat(p99, 'f', 2, 64))
+}
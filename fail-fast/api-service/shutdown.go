@@ -0,0 +1,56 @@
+// api-service/shutdown.go
+// graceful shutdown: drain in-flight requests, then dump the final metrics
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+var shutdownTimeout = getEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second)
+
+// Cancelled once a shutdown signal arrives, so background loops can exit
+var shutdownCtx, beginShutdown = context.WithCancel(context.Background())
+
+// Serve until SIGINT/SIGTERM, then stop accepting, let in-flight requests
+// finish (up to SHUTDOWN_TIMEOUT) and print the run's final metrics
+func serveUntilSignal(listener net.Listener, handler http.Handler) {
+	srv := &http.Server{Handler: handler}
+	go func() {
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+	beginShutdown()
+
+	log.Println("🛑 Shutting down, draining in-flight requests...")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("⚠️ Shutdown did not complete cleanly: %v", err)
+	}
+
+	dumpFinalMetrics()
+}
+
+func dumpFinalMetrics() {
+	summary, err := json.MarshalIndent(currentSnapshot(), "", "  ")
+	if err != nil {
+		log.Printf("⚠️ Could not encode final metrics: %v", err)
+		return
+	}
+	fmt.Println("📊 Final metrics for this run:")
+	fmt.Println(string(summary))
+}
@@ -0,0 +1,128 @@
+// api-service/slidingwindow.go
+// per-second outcome buckets so the shared breaker trips on a sliding
+// window instead of gobreaker's fixed interval
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// nil unless CB_SLIDING_WINDOW is set (whole seconds, e.g. 30s)
+var slidingWindow = newSlidingWindow(getEnvDuration("CB_SLIDING_WINDOW", 0))
+
+// gobreaker's closed-state Interval for the main breaker. The window takes
+// over from it, so with CB_SLIDING_WINDOW the counts never reset (0).
+func closedInterval() time.Duration {
+	if slidingWindow != nil {
+		return 0
+	}
+	return breakerInterval
+}
+
+// Ring of one-second buckets; a bucket is reused once its second has left
+// the window, so old outcomes age out one second at a time rather than all
+// at once at the end of an interval
+type SlidingWindow struct {
+	buckets []outcomeBucket
+	resetAt time.Time
+	mu      sync.Mutex
+}
+
+type outcomeBucket struct {
+	second    int64
+	successes int
+	failures  int
+}
+
+// Windowed counts as reported in /circuit-state
+type SlidingWindowStats struct {
+	Window       string  `json:"window"`
+	Successes    int     `json:"successes"`
+	Failures     int     `json:"failures"`
+	FailureRatio float64 `json:"failure_ratio"`
+}
+
+func newSlidingWindow(window time.Duration) *SlidingWindow {
+	seconds := int(window / time.Second)
+	if seconds <= 0 {
+		return nil
+	}
+	log.Printf("🪟 Breaker trips on a %ds sliding window of outcomes", seconds)
+	return &SlidingWindow{buckets: make([]outcomeBucket, seconds)}
+}
+
+// An empty window of the same length, e.g. for a replay
+func (s *SlidingWindow) fresh() *SlidingWindow {
+	if s == nil {
+		return nil
+	}
+	return &SlidingWindow{buckets: make([]outcomeBucket, len(s.buckets))}
+}
+
+func (s *SlidingWindow) size() time.Duration {
+	return time.Duration(len(s.buckets)) * time.Second
+}
+
+// Count one breaker outcome (declines count as successes, as for gobreaker).
+// Calls that started before the last reset are dropped, so the failure that
+// tripped the circuit doesn't land in the fresh window.
+func (s *SlidingWindow) record(start, now time.Time, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if start.Before(s.resetAt) {
+		return
+	}
+	second := now.Unix()
+	bucket := &s.buckets[second%int64(len(s.buckets))]
+	if bucket.second != second {
+		*bucket = outcomeBucket{second: second}
+	}
+	if success {
+		bucket.successes++
+	} else {
+		bucket.failures++
+	}
+}
+
+// Totals over the buckets still inside the window
+func (s *SlidingWindow) counts(now time.Time) (successes, failures int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	oldest := now.Unix() - int64(len(s.buckets)) + 1
+	for _, bucket := range s.buckets {
+		if bucket.second >= oldest {
+			successes += bucket.successes
+			failures += bucket.failures
+		}
+	}
+	return successes, failures
+}
+
+// Forget everything, e.g. once the circuit has opened on these outcomes
+func (s *SlidingWindow) reset(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.buckets)
+	s.resetAt = now
+}
+
+// The ratio rule of readyToTrip applied to windowed totals
+func windowRatioTrips(successes, failures int) bool {
+	total := successes + failures
+	return total >= breakerMinRequests && float64(failures)/float64(total) >= tripFailureRatio
+}
+
+func (s *SlidingWindow) stats(now time.Time) SlidingWindowStats {
+	successes, failures := s.counts(now)
+	stats := SlidingWindowStats{
+		Window:    s.size().String(),
+		Successes: successes,
+		Failures:  failures,
+	}
+	if total := successes + failures; total > 0 {
+		stats.FailureRatio = float64(failures) / float64(total)
+	}
+	return stats
+}
@@ -0,0 +1,80 @@
+// api-service/slidingwindow_test.go
+// the shared trip policy and generations under CB_SLIDING_WINDOW
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+func withSlidingWindow(t *testing.T, window time.Duration) {
+	t.Helper()
+	saved := slidingWindow
+	t.Cleanup(func() { slidingWindow = saved })
+	slidingWindow = newSlidingWindow(window)
+}
+
+func TestTripPolicyWithWindow(t *testing.T) {
+	window := newSlidingWindow(10 * time.Second)
+	policy := newTripPolicy(nil, window)
+	now := time.Unix(1700000000, 0)
+	for _, success := range []bool{true, false, true, false, true} {
+		window.record(now, now, success)
+	}
+
+	// Alternating outcomes never reach 3 consecutive failures and these
+	// counts alone are below the ratio rule's minimum; the window holds 3 of 6
+	// once the failure being judged is added
+	counts := gobreaker.Counts{Requests: 1, TotalFailures: 1, ConsecutiveFailures: 1}
+	if !policy.readyToTrip(counts, now) {
+		t.Error("3 failures in 6 within the window should trip")
+	}
+	if readyToTrip(counts) {
+		t.Error("the count rules alone should not trip on these counts")
+	}
+	if policy.readyToTrip(counts, now.Add(10*time.Second)) {
+		t.Error("outcomes older than the window should have aged out")
+	}
+
+	counts.ConsecutiveFailures = tripConsecutiveFailures
+	if !policy.readyToTrip(counts, now.Add(10*time.Second)) {
+		t.Error("consecutive failures should still trip with an empty window")
+	}
+}
+
+func TestTripPolicyWithoutWindowIsReadyToTrip(t *testing.T) {
+	policy := newTripPolicy(nil, nil)
+	if policy.window != nil {
+		t.Fatal("a nil *SlidingWindow became a non-nil interface")
+	}
+	for _, counts := range []gobreaker.Counts{
+		{Requests: 2, TotalFailures: 2, ConsecutiveFailures: 2},
+		{Requests: 3, TotalFailures: 3, ConsecutiveFailures: 3},
+		{Requests: 6, TotalFailures: 3, ConsecutiveFailures: 1},
+		{Requests: 6, TotalFailures: 2, ConsecutiveFailures: 1},
+	} {
+		if got, want := policy.readyToTrip(counts, time.Now()), readyToTrip(counts); got != want {
+			t.Errorf("%+v: policy %t, readyToTrip %t", counts, got, want)
+		}
+	}
+}
+
+func TestGenerationsUnderSlidingWindow(t *testing.T) {
+	withSlidingWindow(t, 30*time.Second)
+	if closedInterval() != 0 {
+		t.Fatalf("closed interval %s under the sliding window, want 0", closedInterval())
+	}
+
+	g := newGenerationTracker()
+	g.stateChanged(gobreaker.StateOpen)
+	g.stateChanged(gobreaker.StateHalfOpen)
+	g.stateChanged(gobreaker.StateClosed)
+	if !g.expiry.IsZero() {
+		t.Errorf("closed with an interval expiry at %s, want none", g.expiry)
+	}
+	if got := g.observe(gobreaker.StateClosed); got != 3 {
+		t.Errorf("generation %d, want 3 (state changes only)", got)
+	}
+}
@@ -0,0 +1,104 @@
+// api-service/slowstart.go
+// gradual ramp-up of admitted traffic after the circuit closes again
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Share of the full rate admitted right after the circuit closes
+const slowStartInitialFraction = 0.1
+
+var slowStart = newSlowStartLimiter(
+	getEnvDuration("CB_SLOW_START", 0),
+	getEnvFloat("CB_SLOW_START_MAX_RATE", 50),
+)
+
+// Token bucket whose rate grows linearly from 10% to maxRate over period,
+// starting when the circuit goes half-open → closed
+type SlowStartLimiter struct {
+	period   time.Duration
+	maxRate  float64 // requests per second once fully ramped
+	closedAt time.Time
+	tokens   float64
+	last     time.Time
+	rejected int
+	mu       sync.Mutex
+}
+
+// Slow-start state as reported in /metrics
+type SlowStartStats struct {
+	Active      bool    `json:"active"`
+	AllowedRate float64 `json:"allowed_rate"`
+	Rejected    int     `json:"rejected"`
+}
+
+func newSlowStartLimiter(period time.Duration, maxRate float64) *SlowStartLimiter {
+	if period > 0 {
+		log.Printf("🐢 Slow start after recovery: ramping to %.0f req/s over %s", maxRate, period)
+	}
+	return &SlowStartLimiter{period: period, maxRate: maxRate}
+}
+
+// Start a ramp; called from OnStateChange when the circuit closes
+func (l *SlowStartLimiter) begin() {
+	if l.period <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.closedAt = now
+	l.last = now
+	l.tokens = 1
+	log.Println("🐢 Slow start: ramping traffic back up")
+}
+
+// Current allowed rate; the caller must hold l.mu
+func (l *SlowStartLimiter) rateLocked(now time.Time) (float64, bool) {
+	if l.period <= 0 || l.closedAt.IsZero() {
+		return l.maxRate, false
+	}
+	elapsed := now.Sub(l.closedAt)
+	if elapsed >= l.period {
+		return l.maxRate, false
+	}
+	fraction := slowStartInitialFraction + (1-slowStartInitialFraction)*float64(elapsed)/float64(l.period)
+	return l.maxRate * fraction, true
+}
+
+// Admit a request unless the ramp's current rate has been used up
+func (l *SlowStartLimiter) allow() bool {
+	if l.period <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	rate, active := l.rateLocked(now)
+	if !active {
+		return true
+	}
+
+	l.tokens += rate * now.Sub(l.last).Seconds()
+	l.last = now
+	if burst := max(rate, 1); l.tokens > burst {
+		l.tokens = burst
+	}
+	if l.tokens < 1 {
+		l.rejected++
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+func (l *SlowStartLimiter) stats() SlowStartStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rate, active := l.rateLocked(time.Now())
+	return SlowStartStats{Active: active, AllowedRate: rate, Rejected: l.rejected}
+}
@@ -0,0 +1,61 @@
+// api-service/static.go
+// demo frontend and dashboard, embedded in the binary unless STATIC_DIR points at a disk copy
+package main
+
+import (
+	"embed"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+)
+
+//go:embed static
+var embeddedStatic embed.FS
+
+var staticFiles = newStaticFS(getEnvString("STATIC_DIR", ""))
+
+// Disk is only used when STATIC_DIR is set, e.g. to edit the UI without a rebuild
+func newStaticFS(dir string) fs.FS {
+	if dir != "" {
+		log.Printf("📁 Serving static assets from %s", dir)
+		return os.DirFS(dir)
+	}
+	sub, err := fs.Sub(embeddedStatic, "static")
+	if err != nil {
+		log.Fatalf("❌ Embedded static assets missing: %v", err)
+	}
+	return sub
+}
+
+// Every path gets index.html, whichever filesystem it comes from
+func handleStatic(w http.ResponseWriter, r *http.Request) {
+	serveStaticFile(w, r, "index.html")
+}
+
+// Live charts fed by /metrics/timeseries
+func handleDashboard(w http.ResponseWriter, r *http.Request) {
+	serveStaticFile(w, r, "dashboard.html")
+}
+
+func serveStaticFile(w http.ResponseWriter, r *http.Request, name string) {
+	file, err := staticFiles.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, "Could not read "+name, http.StatusInternalServerError)
+		return
+	}
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		http.Error(w, "Could not read "+name, http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
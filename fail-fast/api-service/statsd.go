@@ -0,0 +1,89 @@
+// api-service/statsd.go
+// optional StatsD/DogStatsD emitter for request outcomes and breaker state
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/sony/gobreaker"
+)
+
+const statsdQueueSize = 1024
+
+// nil unless STATSD_ADDR (host:port) is set
+var statsd = newStatsdEmitter(
+	getEnvString("STATSD_ADDR", ""),
+	getEnvString("STATSD_PREFIX", "checkout"),
+)
+
+// Packets are queued and written by one goroutine, dropping when the queue
+// is full, so a slow or missing collector never blocks a checkout
+type StatsdEmitter struct {
+	prefix  string
+	conn    net.Conn
+	packets chan string
+}
+
+func newStatsdEmitter(addr, prefix string) *StatsdEmitter {
+	if addr == "" {
+		return nil
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		log.Fatalf("❌ Invalid STATSD_ADDR %q: %v", addr, err)
+	}
+	s := &StatsdEmitter{prefix: strings.TrimSuffix(prefix, "."), conn: conn, packets: make(chan string, statsdQueueSize)}
+	go s.run()
+	log.Printf("📡 Emitting StatsD metrics to %s as %s.*", addr, s.prefix)
+	return s
+}
+
+func (s *StatsdEmitter) run() {
+	for packet := range s.packets {
+		// UDP is fire-and-forget; a refused write just loses this packet
+		s.conn.Write([]byte(packet))
+	}
+}
+
+func (s *StatsdEmitter) send(name, value, kind string, state gobreaker.State) {
+	packet := fmt.Sprintf("%s.%s:%s|%s|#circuit_state:%s", s.prefix, name, value, kind, state)
+	if environment != "" {
+		packet += ",environment:" + environment
+	}
+	select {
+	case s.packets <- packet:
+	default:
+	}
+}
+
+// One counter per checkout plus its latency. Fast fails are counted apart
+// from failures so the two add up to the non-success total.
+func (s *StatsdEmitter) emitOutcome(outcome RequestOutcome) {
+	if s == nil {
+		return
+	}
+	name := "success"
+	switch {
+	case outcome.Err == gobreaker.ErrOpenState:
+		name = "fast_fail"
+	case isDeclined(outcome.Err):
+		name = "declined"
+	case outcome.Err != nil:
+		name = "failure"
+	}
+	s.send(name, "1", "c", outcome.State)
+	s.send("latency", fmt.Sprintf("%g", millis(outcome.Latency)), "ms", outcome.State)
+}
+
+// Gauge of the new state (0 closed, 1 half-open, 2 open); safe to call from
+// OnStateChange since it never blocks
+func (s *StatsdEmitter) emitStateChange(to gobreaker.State) {
+	if s == nil {
+		return
+	}
+	s.send("circuit_state", fmt.Sprint(int(to)), "g", to)
+	s.send("state_change", "1", "c", to)
+}
@@ -0,0 +1,84 @@
+// api-service/tenants.go
+// per-tenant payment breakers selected by the X-Tenant-ID header
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/sony/gobreaker"
+)
+
+var (
+	requireTenant  = getEnvBool("REQUIRE_TENANT", false)
+	tenantBreakers = &BreakerRegistry{
+		breakers: map[string]*gobreaker.CircuitBreaker{},
+		limit:    getEnvInt("MAX_TENANTS", 100),
+	}
+)
+
+// Lazily created breakers keyed by tenant, capped so arbitrary header
+// values can't grow the registry without bound
+type BreakerRegistry struct {
+	breakers map[string]*gobreaker.CircuitBreaker
+	limit    int
+	mu       sync.Mutex
+}
+
+// Checkout rejected before reaching a breaker
+type tenantError struct {
+	status  int
+	message string
+}
+
+func (e *tenantError) Error() string { return e.message }
+
+// Pick the breaker for a request: the tenant's own, or the shared one when
+// no X-Tenant-ID is sent (unless REQUIRE_TENANT is set). Without a tenant,
+// high-priority checkouts get the lenient priority breaker.
+func breakerForRequest(r *http.Request) (*gobreaker.CircuitBreaker, *tenantError) {
+	tenant := r.Header.Get("X-Tenant-ID")
+	if tenant == "" {
+		if requireTenant {
+			return nil, &tenantError{http.StatusBadRequest, "X-Tenant-ID header is required"}
+		}
+		if bypassesShedding(r) {
+			return highPriorityCB, nil
+		}
+		return cb, nil
+	}
+	breaker, ok := tenantBreakers.get(tenant)
+	if !ok {
+		return nil, &tenantError{http.StatusServiceUnavailable, "Tenant limit reached"}
+	}
+	return breaker, nil
+}
+
+func (reg *BreakerRegistry) lookup(tenant string) (*gobreaker.CircuitBreaker, bool) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	breaker, ok := reg.breakers[tenant]
+	return breaker, ok
+}
+
+// Get or create the tenant's breaker; false once the registry is full.
+// Tenant breakers use the count-based trip rule and only log state changes.
+func (reg *BreakerRegistry) get(tenant string) (*gobreaker.CircuitBreaker, bool) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if breaker, ok := reg.breakers[tenant]; ok {
+		return breaker, true
+	}
+	if len(reg.breakers) >= reg.limit {
+		return nil, false
+	}
+
+	settings := breakerSettings("payment-service/" + tenant)
+	settings.OnStateChange = func(name string, from gobreaker.State, to gobreaker.State) {
+		log.Printf("🔌 STATE CHANGE [%s]: %s → %s", tenant, from, to)
+	}
+	breaker := gobreaker.NewCircuitBreaker(settings)
+	reg.breakers[tenant] = breaker
+	return breaker, true
+}
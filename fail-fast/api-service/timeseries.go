@@ -0,0 +1,157 @@
+// api-service/timeseries.go
+// bounded history of periodic metric snapshots for the dashboard charts
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// One sample every METRICS_TIMESERIES_INTERVAL (e.g. 2s), keeping the last
+// METRICS_TIMESERIES_SIZE. Off by default: each sample is a full snapshot,
+// which takes metrics.mu and, without LATENCY_HISTOGRAM, sorts the latency
+// history. The dashboard then samples /metrics itself while it is open.
+var timeseries = newTimeseries(
+	getEnvDuration("METRICS_TIMESERIES_INTERVAL", 0),
+	getEnvInt("METRICS_TIMESERIES_SIZE", 300),
+)
+
+// The chartable subset of a MetricsSnapshot; latencies in milliseconds.
+// Counters are cumulative, so per-interval rates come from neighbouring points.
+type TimeseriesPoint struct {
+	Timestamp     time.Time `json:"timestamp"`
+	CircuitState  string    `json:"circuit_state"`
+	TotalRequests int       `json:"total_requests"`
+	SuccessCount  int       `json:"success_count"`
+	FailureCount  int       `json:"failure_count"`
+	FastFails     int       `json:"fast_fails"`
+	SuccessRate   float64   `json:"success_rate"`
+	ErrorRate     float64   `json:"error_rate"`
+	P50Ms         float64   `json:"p50_ms"`
+	P95Ms         float64   `json:"p95_ms"`
+	P99Ms         float64   `json:"p99_ms"`
+}
+
+type Timeseries struct {
+	interval time.Duration
+	points   []TimeseriesPoint // ring, oldest at next once full
+	next     int
+	full     bool
+	mu       sync.Mutex
+}
+
+func newTimeseries(interval time.Duration, size int) *Timeseries {
+	if interval <= 0 || size <= 0 {
+		return nil
+	}
+	return &Timeseries{interval: interval, points: make([]TimeseriesPoint, size)}
+}
+
+// Sample until shutdown
+func (t *Timeseries) start() {
+	if t == nil {
+		return
+	}
+	log.Printf("📈 Recording a metrics timeseries every %s (last %d points)", t.interval, len(t.points))
+	go func() {
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				t.add(timeseriesPoint(now, currentSnapshot()))
+			case <-shutdownCtx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func timeseriesPoint(now time.Time, s MetricsSnapshot) TimeseriesPoint {
+	return TimeseriesPoint{
+		Timestamp:     now.UTC(),
+		CircuitState:  s.CircuitState.String(),
+		TotalRequests: s.TotalRequests,
+		SuccessCount:  s.SuccessCount,
+		FailureCount:  s.FailureCount,
+		FastFails:     s.FastFails,
+		SuccessRate:   s.SuccessRate,
+		ErrorRate:     s.ErrorRate,
+		P50Ms:         millis(s.p50),
+		P95Ms:         millis(s.p95),
+		P99Ms:         millis(s.p99),
+	}
+}
+
+func (t *Timeseries) add(point TimeseriesPoint) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.points[t.next] = point
+	t.next = (t.next + 1) % len(t.points)
+	if t.next == 0 {
+		t.full = true
+	}
+}
+
+// Oldest first, only points taken after since; limit > 0 keeps the newest
+func (t *Timeseries) all(since time.Time, limit int) []TimeseriesPoint {
+	t.mu.Lock()
+	ordered := t.points[:t.next]
+	if t.full {
+		ordered = append(append([]TimeseriesPoint(nil), t.points[t.next:]...), t.points[:t.next]...)
+	}
+	points := []TimeseriesPoint{} // [] rather than null in JSON
+	for _, point := range ordered {
+		if point.Timestamp.After(since) {
+			points = append(points, point)
+		}
+	}
+	t.mu.Unlock()
+
+	if limit > 0 && len(points) > limit {
+		points = points[len(points)-limit:]
+	}
+	return points
+}
+
+// ?since=<RFC 3339 timestamp> returns only newer points, so a poller can pass
+// the last timestamp it saw; ?limit=N keeps the newest N
+func handleMetricsTimeseries(w http.ResponseWriter, r *http.Request) {
+	if timeseries == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{
+			"error": "Timeseries disabled; set METRICS_TIMESERIES_INTERVAL (e.g. 2s)",
+		})
+		return
+	}
+
+	var since time.Time
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "since must be an RFC 3339 timestamp"})
+			return
+		}
+		since = parsed
+	}
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = parsed
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Interval string            `json:"interval"`
+		Size     int               `json:"size"`
+		Points   []TimeseriesPoint `json:"points"`
+	}{
+		Interval: timeseries.interval.String(),
+		Size:     len(timeseries.points),
+		Points:   timeseries.all(since, limit),
+	})
+}
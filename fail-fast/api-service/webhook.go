@@ -0,0 +1,104 @@
+// api-service/webhook.go
+// breaker state-change notifications, delivered through their own breaker
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+const (
+	webhookTimeout   = 2 * time.Second
+	webhookQueueSize = 16
+)
+
+var (
+	webhookURL = getEnvString("STATE_WEBHOOK_URL", "")
+
+	// Separate breaker so a down endpoint costs a fast-fail per event rather
+	// than a full timeout; nil when no webhook is configured
+	webhookCB     = newWebhookBreaker()
+	webhookClient = &http.Client{Timeout: webhookTimeout}
+
+	// One delivery goroutine drains this; events are dropped when it's full
+	webhookQueue = make(chan StateChangeEvent, webhookQueueSize)
+)
+
+// Body POSTed to STATE_WEBHOOK_URL
+type StateChangeEvent struct {
+	Breaker string `json:"breaker"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	At      string `json:"at"`
+}
+
+func newWebhookBreaker() *gobreaker.CircuitBreaker {
+	timeout := getEnvDuration("WEBHOOK_CB_TIMEOUT", 30*time.Second)
+	if webhookURL == "" {
+		return nil
+	}
+	return gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:        "webhook",
+		MaxRequests: 1,
+		Timeout:     timeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= 3
+		},
+		// Log only: notifying about the webhook breaker would loop
+		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+			log.Printf("📮 WEBHOOK STATE CHANGE: %s → %s", from, to)
+		},
+	})
+}
+
+func startWebhookNotifier() {
+	if webhookCB == nil {
+		return
+	}
+	log.Printf("📮 Posting breaker state changes to %s", webhookURL)
+	go func() {
+		for event := range webhookQueue {
+			_, err := webhookCB.Execute(func() (interface{}, error) {
+				return nil, deliverWebhook(event)
+			})
+			if err != nil {
+				log.Printf("📮 Webhook delivery failed (%s → %s): %v", event.From, event.To, err)
+			}
+		}
+	}()
+}
+
+// Queue a notification; safe to call from OnStateChange as it never blocks
+func notifyStateChange(name string, from, to gobreaker.State) {
+	if webhookCB == nil {
+		return
+	}
+	event := StateChangeEvent{Breaker: name, From: from.String(), To: to.String(), At: time.Now().UTC().Format(time.RFC3339)}
+	select {
+	case webhookQueue <- event:
+	default:
+		log.Printf("📮 Webhook queue full, dropping %s → %s", from, to)
+	}
+}
+
+func deliverWebhook(event StateChangeEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
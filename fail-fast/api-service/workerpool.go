@@ -0,0 +1,142 @@
+// api-service/workerpool.go
+// optional fixed worker pool with a bounded queue in front of processCheckout
+package main
+
+import (
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// nil when WORKER_POOL_SIZE is unset: each request is processed on its own
+// handler goroutine as before
+var checkoutPool = newWorkerPool()
+
+// N workers draining a bounded queue. A full queue rejects immediately,
+// which is the backpressure: clients get a 503 instead of piling up.
+type WorkerPool struct {
+	workers  int
+	jobs     chan checkoutJob
+	rejected atomic.Int64
+	idle     atomic.Int64
+	enqueued atomic.Int64 // jobs accepted so far; a job's sequence number
+	dequeued atomic.Int64 // jobs picked up by a worker so far
+}
+
+type checkoutJob struct {
+	r      *http.Request
+	req    CheckoutRequest
+	start  time.Time
+	result chan CheckoutResult // buffered so a worker never blocks on a gone client
+}
+
+// Worker pool state as reported in /metrics
+type WorkerPoolStats struct {
+	Workers       int   `json:"workers"`
+	QueueDepth    int   `json:"queue_depth"`
+	QueueCapacity int   `json:"queue_capacity"`
+	Rejected      int64 `json:"rejected"`
+}
+
+func newWorkerPool() *WorkerPool {
+	workers := getEnvInt("WORKER_POOL_SIZE", 0)
+	queueSize := max(getEnvInt("QUEUE_SIZE", 100), 0)
+	if workers <= 0 {
+		return nil
+	}
+	p := &WorkerPool{workers: workers, jobs: make(chan checkoutJob, queueSize)}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	log.Printf("👷 Processing checkouts on %d workers with a queue of %d", workers, queueSize)
+	if queueAsync {
+		log.Println("📬 QUEUE_ASYNC: checkouts that would wait get a 202 and /api/orders/{id}")
+	}
+	return p
+}
+
+func (p *WorkerPool) work() {
+	p.idle.Add(1)
+	for job := range p.jobs {
+		p.idle.Add(-1)
+		p.dequeued.Add(1)
+		job.result <- p.run(job)
+		p.idle.Add(1)
+	}
+}
+
+// Queue a job without blocking; false when the queue is full
+func (p *WorkerPool) enqueue(job checkoutJob) (int64, bool) {
+	select {
+	case p.jobs <- job:
+		return p.enqueued.Add(1), true
+	default:
+		p.rejected.Add(1)
+		return 0, false
+	}
+}
+
+// True when a new job would sit in the queue rather than start right away
+func (p *WorkerPool) mustWait() bool {
+	return p.idle.Load() == 0 || len(p.jobs) > 0
+}
+
+// Approximate 1-based queue position of a job; 0 once a worker has it
+func (p *WorkerPool) position(seq int64) int64 {
+	return max(seq-p.dequeued.Load(), 0)
+}
+
+// Workers sit outside withRecovery, so a panic is turned into a 500 here
+func (p *WorkerPool) run(job checkoutJob) (result CheckoutResult) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("💥 PANIC [%s] in checkout worker: %v\n%s", requestID(job.r), rec, debug.Stack())
+			recordPanic()
+			result = CheckoutResult{http.StatusInternalServerError, map[string]string{
+				"error":      "Internal server error",
+				"request_id": requestID(job.r),
+			}}
+		}
+	}()
+	return processCheckout(job.r, job.req, job.start)
+}
+
+// Run a checkout on the pool when one is configured, otherwise inline
+func runCheckout(r *http.Request, req CheckoutRequest, start time.Time) CheckoutResult {
+	if checkoutPool == nil {
+		return processCheckout(r, req, start)
+	}
+
+	job := checkoutJob{r: r, req: req, start: start, result: make(chan CheckoutResult, 1)}
+	if _, ok := checkoutPool.enqueue(job); !ok {
+		return queueFullResult(r)
+	}
+
+	select {
+	case result := <-job.result:
+		return result
+	case <-r.Context().Done():
+		return CheckoutResult{statusClientClosedRequest, map[string]string{
+			"error": "Client closed request",
+		}}
+	}
+}
+
+func queueFullResult(r *http.Request) CheckoutResult {
+	log.Printf("👷 QUEUE FULL: Request rejected [%s]", requestID(r))
+	return CheckoutResult{http.StatusServiceUnavailable, map[string]string{
+		"error":  "Checkout queue full",
+		"advice": "Try again shortly",
+	}}
+}
+
+func (p *WorkerPool) stats() WorkerPoolStats {
+	return WorkerPoolStats{
+		Workers:       p.workers,
+		QueueDepth:    len(p.jobs),
+		QueueCapacity: cap(p.jobs),
+		Rejected:      p.rejected.Load(),
+	}
+}
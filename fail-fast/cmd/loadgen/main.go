@@ -0,0 +1,202 @@
+// cmd/loadgen/main.go
+// standalone load generator for demonstrating the circuit breaker
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Same shape as the api-service CheckoutRequest (that type lives in a
+// main package and can't be imported)
+type CheckoutRequest struct {
+	Item     string  `json:"item"`
+	Price    float64 `json:"price"`
+	Currency string  `json:"currency,omitempty"`
+	Quantity *int    `json:"quantity,omitempty"`
+}
+
+// One client-observed checkout; Status is 0 when no response arrived
+type result struct {
+	Status  int
+	Latency time.Duration
+}
+
+type collector struct {
+	mu      sync.Mutex
+	results []result
+}
+
+func (c *collector) add(r result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = append(c.results, r)
+}
+
+func (c *collector) all() []result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]result, len(c.results))
+	copy(out, c.results)
+	return out
+}
+
+func main() {
+	target := flag.String("url", "http://localhost:8080/api/checkout", "checkout endpoint to load")
+	rate := flag.Float64("rate", 10, "starting request rate per second")
+	rampTo := flag.Float64("ramp-to", 0, "rate to ramp up to linearly over the run (0 = constant rate)")
+	duration := flag.Duration("duration", 30*time.Second, "how long to generate load")
+	maxInFlight := flag.Int("max-inflight", 500, "requests allowed in flight before new ones are dropped")
+	item := flag.String("item", "Widget", "item to check out")
+	price := flag.Float64("price", 19.99, "price to charge")
+	timeout := flag.Duration("timeout", 10*time.Second, "client-side request timeout")
+	flag.Parse()
+
+	if *rate <= 0 || *duration <= 0 || *maxInFlight <= 0 {
+		log.Fatal("❌ -rate, -duration and -max-inflight must be positive")
+	}
+	endRate := *rate
+	if *rampTo > 0 {
+		endRate = *rampTo
+	}
+
+	body, err := json.Marshal(CheckoutRequest{Item: *item, Price: *price})
+	if err != nil {
+		log.Fatalf("❌ Encoding request: %v", err)
+	}
+	client := &http.Client{Timeout: *timeout}
+
+	fmt.Printf("🚀 %s for %s, %.1f → %.1f req/s\n", *target, *duration, *rate, endRate)
+
+	var (
+		results  collector
+		wg       sync.WaitGroup
+		dropped  int
+		inFlight = make(chan struct{}, *maxInFlight)
+		start    = time.Now()
+		next     = start
+		lastTick = start
+		sent     int
+	)
+	for {
+		elapsed := time.Since(start)
+		if elapsed >= *duration {
+			break
+		}
+		// Current target rate, interpolated between -rate and -ramp-to
+		current := *rate + (endRate-*rate)*elapsed.Seconds()/duration.Seconds()
+		next = next.Add(time.Duration(float64(time.Second) / current))
+		time.Sleep(time.Until(next))
+
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			dropped++
+			continue
+		}
+		sent++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+			results.add(checkout(client, *target, body))
+		}()
+
+		if time.Since(lastTick) >= time.Second {
+			lastTick = time.Now()
+			printProgress(time.Since(start), current, results.all())
+		}
+	}
+	wg.Wait()
+
+	fmt.Println()
+	printSummary(results.all(), sent, dropped, time.Since(start))
+}
+
+func checkout(client *http.Client, target string, body []byte) result {
+	start := time.Now()
+	resp, err := client.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return result{Latency: time.Since(start)}
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return result{Status: resp.StatusCode, Latency: time.Since(start)}
+}
+
+// One line per second so a breaker trip shows up as the 503s take over
+func printProgress(elapsed time.Duration, rate float64, results []result) {
+	fmt.Printf("⏱️ %5.1fs  %6.1f req/s  completed=%d  by status: %s\n",
+		elapsed.Seconds(), rate, len(results), statusBreakdown(results))
+}
+
+func printSummary(results []result, sent, dropped int, elapsed time.Duration) {
+	fmt.Printf("📊 Sent %d requests in %s (%.1f req/s), %d dropped at -max-inflight\n",
+		sent, elapsed.Round(time.Millisecond), float64(sent)/elapsed.Seconds(), dropped)
+	if len(results) == 0 {
+		fmt.Println("No responses recorded")
+		return
+	}
+
+	failures := 0
+	latencies := make([]time.Duration, len(results))
+	for i, r := range results {
+		latencies[i] = r.Latency
+		if r.Status != http.StatusOK {
+			failures++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	fmt.Printf("   Status:     %s\n", statusBreakdown(results))
+	fmt.Printf("   Error rate: %.1f%%\n", float64(failures)/float64(len(results))*100)
+	fmt.Printf("   Latency:    p50=%s p95=%s p99=%s max=%s\n",
+		percentile(latencies, 0.50), percentile(latencies, 0.95),
+		percentile(latencies, 0.99), latencies[len(latencies)-1].Round(time.Microsecond))
+}
+
+func statusBreakdown(results []result) string {
+	counts := map[int]int{}
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	statuses := make([]int, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+
+	var b bytes.Buffer
+	for i, status := range statuses {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		if status == 0 {
+			fmt.Fprintf(&b, "error=%d", counts[status])
+		} else {
+			fmt.Fprintf(&b, "%d=%d", status, counts[status])
+		}
+	}
+	return b.String()
+}
+
+// Nearest-rank percentile of an already sorted slice
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	index := int(math.Ceil(float64(len(sorted))*p)) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+	return sorted[index].Round(time.Microsecond)
+}
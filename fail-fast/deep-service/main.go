@@ -0,0 +1,49 @@
+// deep-service/main.go
+// the flaky service's own dependency, for two-hop failure scenarios
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+func main() {
+	rand.Seed(time.Now().UnixNano())
+
+	failureRate := getEnvFloat("DEEP_FAILURE_RATE", 0.3)
+
+	http.HandleFunc("/process", func(w http.ResponseWriter, r *http.Request) {
+		if rand.Float64() < failureRate {
+			fmt.Println("❌ Deep dependency failing...")
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "Ledger unavailable!")
+			return
+		}
+
+		fmt.Println("✅ Deep dependency OK")
+		fmt.Fprintf(w, "Ledger updated!")
+	})
+
+	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Deep service is running")
+	})
+
+	fmt.Printf("🏦 Deep Ledger Service starting on :8082 (failure rate %.0f%%)\n", failureRate*100)
+	http.ListenAndServe(":8082", nil)
+}
+
+// Get a float setting from the environment with default
+func getEnvFloat(key string, fallback float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+		fmt.Printf("⚠️ Invalid %s %q, using %g\n", key, value, fallback)
+	}
+	return fallback
+}
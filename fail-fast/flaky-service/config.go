@@ -0,0 +1,102 @@
+// flaky-service/config.go
+// settings lookup: environment variables first, then the optional CONFIG_FILE
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// Values from CONFIG_FILE, keyed by the same names as the env vars
+	fileSettings = loadConfigFile(os.Getenv("CONFIG_FILE"), "flaky")
+
+	// Every setting read so far
+	readSettings = map[string]bool{}
+)
+
+// Load a JSON or YAML object of setting names to scalar values, e.g.
+// {"FLAKY_FAIL_FIRST_N": 5}. A file shared with api-service nests them under
+// "api" and "flaky" sections instead, and only this service's section is read.
+func loadConfigFile(path string, section string) map[string]string {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("❌ Could not read CONFIG_FILE: %v", err)
+	}
+
+	var raw map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	default:
+		err = json.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		log.Fatalf("❌ Could not parse CONFIG_FILE %s: %v", path, err)
+	}
+	raw = configSection(raw, section)
+
+	settings := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch value.(type) {
+		case string, bool, int, float64:
+			settings[key] = fmt.Sprint(value)
+		default:
+			log.Fatalf("❌ CONFIG_FILE: %s must be a string, number or boolean", key)
+		}
+	}
+	fmt.Printf("📄 Loaded %d settings from %s\n", len(settings), path)
+	return settings
+}
+
+// The named section of a sectioned file, or the whole file when it has no
+// sections. Other services' sections are left to them to validate.
+func configSection(raw map[string]interface{}, section string) map[string]interface{} {
+	sectioned := false
+	for _, value := range raw {
+		if _, ok := value.(map[string]interface{}); ok {
+			sectioned = true
+			break
+		}
+	}
+	if !sectioned {
+		return raw
+	}
+	for key, value := range raw {
+		if _, ok := value.(map[string]interface{}); !ok {
+			log.Fatalf("❌ CONFIG_FILE: %s is outside any section", key)
+		}
+	}
+	own, _ := raw[section].(map[string]interface{})
+	return own
+}
+
+// Look up a setting: the environment overrides the config file
+func getSetting(key string) string {
+	readSettings[key] = true
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fileSettings[key]
+}
+
+// Reject unknown keys in CONFIG_FILE (likely typos). Run from main, after all
+// settings have been read.
+func checkConfigFile() {
+	for key := range fileSettings {
+		if !readSettings[key] {
+			log.Fatalf("❌ CONFIG_FILE: unknown setting %s", key)
+		}
+	}
+}
@@ -3,22 +3,125 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"math/rand"
+	"math"
+	mrand "math/rand"
+	"net"
 	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
+	mrand.Seed(time.Now().UnixNano())
+
+	// Cold-start mode: fail the first N requests, then behave normally
+	failFirstN := getEnvInt("FLAKY_FAIL_FIRST_N", 0)
+	var processed int64
+
+	// Optional deeper dependency called on every /process
+	deepURL := getEnvString("DEEP_SERVICE_URL", "")
+	deepClient := &http.Client{Timeout: 2 * time.Second}
+
+	// Misbehaving-endpoint mode: redirect FLAKY_REDIRECT_PCT% of requests
+	redirectPct := getEnvFloat("FLAKY_REDIRECT_PCT", 0)
+	redirectURL := getEnvString("FLAKY_REDIRECT_URL", "/health")
+
+	// Transport-failure mode: drop FLAKY_RESET_PCT% of connections mid-request
+	resetPct := getEnvFloat("FLAKY_RESET_PCT", 0)
+
+	// Business-failure mode: answer FLAKY_SOFT_FAIL_PCT% with a 200 whose body says it failed
+	softFailPct := getEnvFloat("FLAKY_SOFT_FAIL_PCT", 0)
+
+	// Business rule: reject charges above FLAKY_MAX_AMOUNT with 402
+	maxAmount := getEnvFloat("FLAKY_MAX_AMOUNT", 0)
+
+	// Rate-limited dependency: over FLAKY_RATE_LIMIT req/s get 429 + Retry-After
+	limiter := newRateLimiter(getEnvFloat("FLAKY_RATE_LIMIT", 0))
+
+	// Health hint: report in-flight requests / FLAKY_CAPACITY as X-Load
+	capacity := getEnvInt("FLAKY_CAPACITY", 0)
+	var inFlight int64
+
+	checkConfigFile()
 
 	http.HandleFunc("/process", func(w http.ResponseWriter, r *http.Request) {
+		// Log with the caller's request ID so one checkout can be traced across services
+		id := r.Header.Get("X-Request-ID")
+		if id == "" {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+
+		n := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		if capacity > 0 {
+			w.Header().Set("X-Load", strconv.FormatFloat(float64(n)/float64(capacity), 'f', 2, 64))
+		}
+
+		if wait, ok := limiter.allow(); !ok {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			fmt.Printf("[%s] 🚦 Throttled, retry after %ds\n", id, retryAfter)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprintf(w, "Rate limit exceeded!")
+			return
+		}
+
+		if maxAmount > 0 {
+			if amount, err := strconv.ParseFloat(r.URL.Query().Get("amount"), 64); err == nil && amount > maxAmount {
+				fmt.Printf("[%s] 🚫 Declining $%.2f (limit $%.2f)\n", id, amount, maxAmount)
+				w.WriteHeader(http.StatusPaymentRequired)
+				fmt.Fprintf(w, "Amount exceeds limit!")
+				return
+			}
+		}
+
+		if n := atomic.AddInt64(&processed, 1); n <= int64(failFirstN) {
+			fmt.Printf("[%s] 🧊 Cold start failure %d/%d\n", id, n, failFirstN)
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "Service warming up!")
+			return
+		}
+
+		if deepURL != "" {
+			if err := callDeepService(deepClient, deepURL); err != nil {
+				fmt.Printf("[%s] 🔗 Deep dependency failed: %v\n", id, err)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprintf(w, "Upstream ledger unavailable!")
+				return
+			}
+		}
+
+		if resetPct > 0 && mrand.Float64()*100 < resetPct {
+			fmt.Printf("[%s] 🔌 Resetting connection\n", id)
+			resetConnection(w)
+			return
+		}
+
+		if redirectPct > 0 && mrand.Float64()*100 < redirectPct {
+			fmt.Printf("[%s] ↪️ Redirecting to %s\n", id, redirectURL)
+			http.Redirect(w, r, redirectURL, http.StatusFound)
+			return
+		}
+
+		if softFailPct > 0 && mrand.Float64()*100 < softFailPct {
+			fmt.Printf("[%s] 🙃 Soft failure: 200 with success=false\n", id)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"success":false,"reason":"declined"}`)
+			return
+		}
+
 		// Simulate random failures and slow responses
-		randomValue := rand.Float32()
+		randomValue := mrand.Float32()
 
 		if randomValue < 0.3 {
 			// 30% chance: Timeout (very slow)
-			fmt.Println("Simulating a timeout...")
+			fmt.Printf("[%s] Simulating a timeout...\n", id)
 			time.Sleep(5 * time.Second)
 			w.WriteHeader(http.StatusInternalServerError)
 			fmt.Fprintf(w, "Service overloaded!")
@@ -27,14 +130,14 @@ func main() {
 
 		if randomValue < 0.5 {
 			// 20% chance: Quick failure
-			fmt.Println("❌ Simulating quick failure...")
+			fmt.Printf("[%s] ❌ Simulating quick failure...\n", id)
 			w.WriteHeader(http.StatusInternalServerError)
 			fmt.Fprintf(w, "Payment processor error!")
 			return
 		}
 
 		// 50% chance: Success
-		fmt.Println("✅ Payment processed successfully")
+		fmt.Printf("[%s] ✅ Payment processed successfully\n", id)
 		fmt.Fprintf(w, "Payment successful!")
 	})
 
@@ -42,6 +145,124 @@ func main() {
 		fmt.Fprintf(w, "Flaky service is running")
 	})
 
+	if failFirstN > 0 {
+		fmt.Printf("🧊 Failing the first %d requests\n", failFirstN)
+	}
+	if deepURL != "" {
+		fmt.Printf("🔗 Calling deep dependency at %s\n", deepURL)
+	}
+	if softFailPct > 0 {
+		fmt.Printf("🙃 Soft-failing %.0f%% of payments with a 200\n", softFailPct)
+	}
+	if limiter != nil {
+		fmt.Printf("🚦 Rate limiting /process to %g req/s\n", limiter.rate)
+	}
+	if capacity > 0 {
+		fmt.Printf("📈 Reporting X-Load against a capacity of %d\n", capacity)
+	}
 	fmt.Println("💳 Flaky Payment Service starting on :8081")
 	http.ListenAndServe(":8081", nil)
 }
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// Abort the connection without an HTTP response. SO_LINGER 0 makes the close
+// send a TCP RST, so the caller sees ECONNRESET rather than a clean EOF.
+func resetConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// Token bucket refilled at rate tokens/s, holding at most max(rate, 1)
+type rateLimiter struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+// nil (no limit) unless rate is positive
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: rate, tokens: math.Max(rate, 1), last: time.Now()}
+}
+
+// Take a token; when none is left, also report how long until one is
+func (l *rateLimiter) allow() (time.Duration, bool) {
+	if l == nil {
+		return 0, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = math.Min(l.tokens+l.rate*now.Sub(l.last).Seconds(), math.Max(l.rate, 1))
+	l.last = now
+	if l.tokens < 1 {
+		return time.Duration((1 - l.tokens) / l.rate * float64(time.Second)), false
+	}
+	l.tokens--
+	return 0, true
+}
+
+func callDeepService(client *http.Client, baseURL string) error {
+	resp, err := client.Get(baseURL + "/process")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("deep service error (%d: %s)", resp.StatusCode, resp.Status)
+	}
+	return nil
+}
+
+// Get a string setting with default
+func getEnvString(key string, fallback string) string {
+	if value := getSetting(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Get a float setting from the environment with default
+func getEnvFloat(key string, fallback float64) float64 {
+	if value := getSetting(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+		fmt.Printf("⚠️ Invalid %s %q, using %g\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// Get an integer setting from the environment with default
+func getEnvInt(key string, fallback int) int {
+	if value := getSetting(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		fmt.Printf("⚠️ Invalid %s %q, using %d\n", key, value, fallback)
+	}
+	return fallback
+}